@@ -0,0 +1,215 @@
+package locker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Administrative operations.
+const (
+	OpForceRelease = "force-release"
+	OpTransfer     = "transfer"
+	OpRevoke       = "revoke"
+)
+
+// Admin makes administrative changes to locks, for when a holder is
+// wedged and its locks have to be taken away from it. Every change needs
+// a reason, and is recorded in Audit along with it before it's made. A
+// change which then fails is recorded again, with its error.
+//
+//     admin := locker.Admin{Store: client.Store, Audit: &locker.JSONAuditor{W: logFile}}
+//     action, err := admin.ForceRelease(ctx, "nightly-backup", "", "backup host died")
+//
+type Admin struct {
+	Store Store
+
+	// Audit records the changes made. It's required.
+	Audit Auditor
+
+	// DryRun makes the operations return the changes they would make,
+	// without making or recording them.
+	DryRun bool
+}
+
+// AdminAction is a change made by an Admin, as it's recorded.
+type AdminAction struct {
+	Op     string    `json:"op"`
+	Name   string    `json:"name"`
+	Holder string    `json:"holder"`
+	To     string    `json:"to,omitempty"`
+	Reason string    `json:"reason"`
+	DryRun bool      `json:"dry_run,omitempty"`
+	Time   time.Time `json:"time"`
+
+	// Error is why the change failed, once it was recorded.
+	Error string `json:"error,omitempty"`
+}
+
+// Auditor records the changes made by an Admin.
+type Auditor interface {
+	Record(ctx context.Context, action AdminAction) error
+}
+
+// JSONAuditor records changes as JSON, one document per line.
+type JSONAuditor struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+// Record writes action to W.
+func (a *JSONAuditor) Record(ctx context.Context, action AdminAction) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return json.NewEncoder(a.W).Encode(action)
+}
+
+// ForceRelease releases the named lock, whoever holds it. If holder
+// isn't empty the lock is only released while it's held by that value.
+// Either way, a lock which changed hands since it was looked at is left
+// alone, and LockDenied returned: atomically if the Store is a
+// CompareAndDeleter, and otherwise on a best-effort basis, as the value
+// is checked again right before the lock is deleted.
+func (a Admin) ForceRelease(ctx context.Context, name, holder, reason string) (AdminAction, error) {
+	action, err := a.prepare(ctx, OpForceRelease, name, holder, reason)
+	if err != nil || a.DryRun {
+		return action, err
+	}
+
+	return action, a.change(ctx, action, func() error {
+		return compareAndDelete(ctx, a.Store, name, action.Holder)
+	})
+}
+
+// Transfer hands the named lock over to the value to, atomically, so
+// nobody else can take it in between. If from isn't empty the lock is
+// only transferred while it's held by that value. The Store has to be a
+// Transferer, otherwise Unsupported is returned.
+func (a Admin) Transfer(ctx context.Context, name, from, to, reason string) (AdminAction, error) {
+	transferer, ok := a.Store.(Transferer)
+	if !ok {
+		return AdminAction{}, Unsupported{"Transfer"}
+	}
+	if to == "" {
+		return AdminAction{}, errors.New("locker: a lock can't be transferred to an empty value")
+	}
+
+	action, err := a.prepare(ctx, OpTransfer, name, from, reason)
+	action.To = to
+	if err != nil || a.DryRun {
+		return action, err
+	}
+
+	return action, a.change(ctx, action, func() error {
+		return transferer.Transfer(ctx, name, action.Holder, to)
+	})
+}
+
+// Revoke releases every lock whose name starts with prefix, and returns
+// a change for each, in name order. The Store has to be a Lister,
+// otherwise Unsupported is returned. Like with ForceRelease, a lock which
+// changed hands since it was listed is left alone; it's recorded with
+// its error, but not returned. Revoke stops at the first lock it fails
+// to release otherwise; the changes made until then are returned with
+// the error.
+func (a Admin) Revoke(ctx context.Context, prefix, reason string) ([]AdminAction, error) {
+	if reason == "" {
+		return nil, ReasonRequired{OpRevoke}
+	}
+	lister, ok := a.Store.(Lister)
+	if !ok {
+		return nil, Unsupported{"List"}
+	}
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+
+	locks, err := lister.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(locks))
+	for name := range locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var actions []AdminAction
+	for _, name := range names {
+		action := a.action(OpRevoke, name, locks[name], reason)
+		if !a.DryRun {
+			err := a.change(ctx, action, func() error {
+				return compareAndDelete(ctx, a.Store, name, action.Holder)
+			})
+			switch err.(type) {
+			case nil:
+			case LockDenied, LockNotFound:
+				continue
+			default:
+				return actions, err
+			}
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// prepare checks an operation on a single lock, and describes it.
+func (a Admin) prepare(ctx context.Context, op, name, holder, reason string) (AdminAction, error) {
+	if reason == "" {
+		return AdminAction{}, ReasonRequired{op}
+	}
+	if err := a.check(); err != nil {
+		return AdminAction{}, err
+	}
+
+	v, err := a.Store.Get(ctx, name)
+	if err != nil {
+		return AdminAction{}, err
+	}
+	if holder != "" && v != holder {
+		return AdminAction{}, LockDenied{name}
+	}
+	return a.action(op, name, v, reason), nil
+}
+
+func (a Admin) action(op, name, holder, reason string) AdminAction {
+	return AdminAction{
+		Op:     op,
+		Name:   name,
+		Holder: holder,
+		Reason: reason,
+		DryRun: a.DryRun,
+		Time:   time.Now().UTC(),
+	}
+}
+
+func (a Admin) check() error {
+	if a.Audit == nil && !a.DryRun {
+		return errors.New("locker: Admin needs an Audit to record its changes in")
+	}
+	return nil
+}
+
+// change records action, and then makes it with do. It isn't made if it
+// couldn't be recorded, so no change goes unrecorded, even if the process
+// dies while making it. A change do fails is recorded again with its
+// error.
+func (a Admin) change(ctx context.Context, action AdminAction, do func() error) error {
+	if err := a.Audit.Record(ctx, action); err != nil {
+		return errors.New("locker: " + action.Op + " of " + action.Name + " wasn't made, as it couldn't be recorded: " + err.Error())
+	}
+
+	err := do()
+	if err != nil {
+		action.Error = err.Error()
+		// the change wasn't made, so the error is what matters
+		a.Audit.Record(ctx, action)
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+package locker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestAdminForceRelease(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryStore{}
+	var log bytes.Buffer
+	admin := Admin{Store: store, Audit: &JSONAuditor{W: &log}}
+
+	store.AcquireOrFreshenLock(ctx, "job", "a")
+
+	if _, err := admin.ForceRelease(ctx, "job", "", ""); err == nil {
+		t.Error("Expected a reason to be required")
+	}
+	if _, err := admin.ForceRelease(ctx, "job", "b", "wedged"); err == nil {
+		t.Error("Expected a lock held by another holder to be left alone")
+	}
+
+	action, err := admin.ForceRelease(ctx, "job", "a", "wedged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action.Op != OpForceRelease || action.Holder != "a" || action.Reason != "wedged" {
+		t.Errorf("Unexpected action %+v", action)
+	}
+	if _, err := store.Get(ctx, "job"); err == nil {
+		t.Error("Expected the lock to be released")
+	}
+
+	var recorded AdminAction
+	if err := json.Unmarshal(log.Bytes(), &recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded.Name != "job" || recorded.Reason != "wedged" {
+		t.Errorf("Expected the release to be recorded, got %+v", recorded)
+	}
+}
+
+func TestAdminTransfer(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryStore{}
+	var log bytes.Buffer
+	admin := Admin{Store: store, Audit: &JSONAuditor{W: &log}}
+
+	store.AcquireOrFreshenLock(ctx, "job", "a")
+
+	dry := admin
+	dry.DryRun = true
+	if action, err := dry.Transfer(ctx, "job", "", "b", "failover"); err != nil || !action.DryRun || action.To != "b" {
+		t.Errorf("Dry run: %+v %v", action, err)
+	}
+	if v, _ := store.Get(ctx, "job"); v != "a" || log.Len() > 0 {
+		t.Errorf("Expected a dry run to change nothing, got %q and %q recorded", v, log.String())
+	}
+
+	if _, err := admin.Transfer(ctx, "job", "", "b", "failover"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.Get(ctx, "job"); v != "b" {
+		t.Errorf("Expected the lock to be transferred, got %q", v)
+	}
+
+	if _, err := (Admin{Store: &memoryStore{}, Audit: admin.Audit}).Transfer(ctx, "job", "", "b", "failover"); err == nil {
+		t.Error("Expected Transfer to be unsupported by a Store which isn't a Transferer")
+	}
+}
+
+func TestAdminRevoke(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryStore{}
+	var log bytes.Buffer
+	admin := Admin{Store: store, Audit: &JSONAuditor{W: &log}}
+
+	store.AcquireOrFreshenLock(ctx, "jobs/b", "2")
+	store.AcquireOrFreshenLock(ctx, "jobs/a", "1")
+	store.AcquireOrFreshenLock(ctx, "other", "3")
+
+	actions, err := admin.Revoke(ctx, "jobs/", "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 || actions[0].Name != "jobs/a" || actions[1].Holder != "2" {
+		t.Errorf("Unexpected actions %+v", actions)
+	}
+	if locks, _ := store.List(ctx, ""); len(locks) != 1 || locks["other"] != "3" {
+		t.Errorf("Expected only the locks under the prefix to be revoked, left %v", locks)
+	}
+	if n := bytes.Count(log.Bytes(), []byte("\n")); n != 2 {
+		t.Errorf("Expected 2 recorded changes, got %d", n)
+	}
+
+	if _, err := (Admin{Store: store}).Revoke(ctx, "jobs/", "deploy"); err == nil {
+		t.Error("Expected an Admin without an Audit to refuse changes")
+	}
+}
+
+// handoverStore hands its locks over to "b" as soon as they're looked at.
+type handoverStore struct {
+	MemoryStore
+}
+
+func (s *handoverStore) Get(ctx context.Context, name string) (string, error) {
+	v, err := s.MemoryStore.Get(ctx, name)
+	if err == nil {
+		s.MemoryStore.Transfer(ctx, name, v, "b")
+	}
+	return v, err
+}
+
+func (s *handoverStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	locks, err := s.MemoryStore.List(ctx, prefix)
+	for name, v := range locks {
+		s.MemoryStore.Transfer(ctx, name, v, "b")
+	}
+	return locks, err
+}
+
+func TestAdminLeavesChangedHands(t *testing.T) {
+	ctx := context.Background()
+	store := &handoverStore{}
+	var log bytes.Buffer
+	admin := Admin{Store: store, Audit: &JSONAuditor{W: &log}}
+
+	store.AcquireOrFreshenLock(ctx, "job", "a")
+	if _, err := admin.ForceRelease(ctx, "job", "", "wedged"); err != (LockDenied{"job"}) {
+		t.Fatalf("Expected LockDenied, got %v", err)
+	}
+	if v, _ := store.MemoryStore.Get(ctx, "job"); v != "b" {
+		t.Errorf("Expected the new holder's lock to be left alone, got %q", v)
+	}
+
+	var intent, failed AdminAction
+	dec := json.NewDecoder(&log)
+	if err := dec.Decode(&intent); err != nil || intent.Error != "" {
+		t.Errorf("Expected the change to be recorded first, got %+v, %v", intent, err)
+	}
+	if err := dec.Decode(&failed); err != nil || failed.Error == "" {
+		t.Errorf("Expected the failure to be recorded, got %+v, %v", failed, err)
+	}
+
+	store.AcquireOrFreshenLock(ctx, "jobs/a", "1")
+	actions, err := admin.Revoke(ctx, "jobs/", "deploy")
+	if err != nil || len(actions) != 0 {
+		t.Errorf("Expected a lock which changed hands to be left out, got %+v, %v", actions, err)
+	}
+}
+
+type failingAuditor struct{}
+
+func (failingAuditor) Record(ctx context.Context, action AdminAction) error {
+	return errors.New("disk full")
+}
+
+func TestAdminRecordsFirst(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryStore{}
+	admin := Admin{Store: store, Audit: failingAuditor{}}
+
+	store.AcquireOrFreshenLock(ctx, "job", "a")
+	if _, err := admin.ForceRelease(ctx, "job", "", "wedged"); err == nil {
+		t.Fatal("Expected the failure to record to be returned")
+	}
+	if v, _ := store.Get(ctx, "job"); v != "a" {
+		t.Errorf("Expected a change which couldn't be recorded not to be made, got %q", v)
+	}
+}
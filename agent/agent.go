@@ -0,0 +1,415 @@
+// Package agent serves locks to the processes of a host over a Unix
+// socket, so they share the agent's one connection to the Store instead
+// of each opening their own. It's what locker-agent runs.
+//
+// A process holds its locks for as long as it keeps its connection to
+// the agent open. The agent keeps them fresh in the Store meanwhile, and
+// releases them when the connection closes, which the kernel does for a
+// process which dies.
+//
+// The protocol is one JSON Message per line in either direction. Requests
+// carry an id the response echoes:
+//
+//	{"id": 1, "op": "acquire", "name": "job", "value": "host-a"}
+//	{"id": 1, "op": "acquire", "name": "job", "value": "host-a", "locked": true}
+//
+//	{"id": 2, "op": "get", "name": "job"}
+//	{"id": 3, "op": "release", "name": "job"}
+//
+// Without a value a lock is taken with "<hostname>:<pid>" of the
+// requesting process, found through its peer credentials on Linux.
+// Failures answer with an error and one of the codes of package server.
+// A lock which is lost in the Store is pushed to its holder without an
+// id:
+//
+//	{"op": "lost", "name": "job", "value": "host-a"}
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// Operations of the protocol.
+const (
+	OpAcquire = "acquire"
+	OpRelease = "release"
+	OpGet     = "get"
+	OpLost    = "lost"
+)
+
+// Error codes of the protocol, the same as those of package server.
+const (
+	CodeDenied     = "denied"
+	CodeNotFound   = "not_found"
+	CodeBadRequest = "bad_request"
+	CodeStore      = "store"
+)
+
+// Message is a request or a response of the protocol.
+type Message struct {
+	ID     uint64 `json:"id,omitempty"`
+	Op     string `json:"op"`
+	Name   string `json:"name,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Locked bool   `json:"locked,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// Agent serves the locks of a Store to the processes of the host. Create
+// it with New and set its fields before it serves its first connection.
+type Agent struct {
+	// Store is where the locks are kept.
+	Store locker.Store
+
+	// Refresh is how often the held locks are freshened in the Store. It
+	// has to be shorter than the TTL of the Store. Default: 1s.
+	Refresh time.Duration
+
+	start sync.Once
+	quit  chan struct{}
+	done  chan struct{}
+
+	mu        sync.Mutex
+	locks     map[string]*hold
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+}
+
+// hold is a lock the agent holds on behalf of a connection.
+type hold struct {
+	value string
+	owner *conn
+}
+
+// conn is a process connected to the agent.
+type conn struct {
+	net.Conn
+	peer Peer
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// Peer is the process at the other end of a connection, as far as the
+// kernel tells.
+type Peer struct {
+	PID int
+	UID int
+	GID int
+}
+
+// New creates an Agent for store.
+func New(store locker.Store) *Agent {
+	return &Agent{
+		Store:     store,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+		locks:     make(map[string]*hold),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the Unix socket at path and serves it. A
+// socket left behind by a previous agent is removed first, but a socket
+// another agent still serves is left to it.
+func (a *Agent) ListenAndServe(path string) error {
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return fmt.Errorf("agent: %s is served by another agent", path)
+	} else if errors.Is(err, syscall.ECONNREFUSED) {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return a.Serve(l)
+}
+
+// Serve serves the connections of l until the agent is closed.
+func (a *Agent) Serve(l net.Listener) error {
+	a.start.Do(func() { go a.refresh() })
+
+	a.mu.Lock()
+	a.listeners[l] = struct{}{}
+	a.mu.Unlock()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			select {
+			case <-a.quit:
+				return nil
+			default:
+				return err
+			}
+		}
+		go a.serve(c)
+	}
+}
+
+// Close stops serving, and releases every lock held through the agent.
+func (a *Agent) Close() error {
+	// if nothing was ever served there's no refresher to wait for
+	a.start.Do(func() { close(a.done) })
+	close(a.quit)
+
+	a.mu.Lock()
+	for l := range a.listeners {
+		l.Close()
+	}
+	for c := range a.conns {
+		c.Close()
+	}
+	a.mu.Unlock()
+
+	<-a.done
+	a.releaseAll(func(h *hold) bool { return true })
+	return nil
+}
+
+func (a *Agent) serve(nc net.Conn) {
+	c := &conn{Conn: nc, peer: peerOf(nc), enc: json.NewEncoder(nc)}
+
+	a.mu.Lock()
+	a.conns[c] = struct{}{}
+	a.mu.Unlock()
+
+	defer func() {
+		c.Close()
+		a.mu.Lock()
+		delete(a.conns, c)
+		a.mu.Unlock()
+		a.releaseAll(func(h *hold) bool { return h.owner == c })
+	}()
+
+	lines := bufio.NewScanner(c)
+	for lines.Scan() {
+		var req Message
+		if err := json.Unmarshal(lines.Bytes(), &req); err != nil {
+			c.send(Message{Error: "invalid request: " + err.Error(), Code: CodeBadRequest})
+			continue
+		}
+		c.send(a.handle(c, req))
+	}
+}
+
+func (a *Agent) handle(c *conn, req Message) Message {
+	resp := Message{ID: req.ID, Op: req.Op, Name: req.Name}
+	fail := func(err error) Message {
+		resp.Error, resp.Code = toError(err)
+		return resp
+	}
+
+	if req.Name == "" {
+		return fail(badRequest("name is required"))
+	}
+	ctx := context.Background()
+
+	switch req.Op {
+	case OpAcquire:
+		value := req.Value
+		if value == "" {
+			value = c.defaultValue()
+		}
+		if err := a.acquire(ctx, c, req.Name, value); err != nil {
+			return fail(err)
+		}
+		resp.Value, resp.Locked = value, true
+
+	case OpRelease:
+		if err := a.release(ctx, c, req.Name); err != nil {
+			return fail(err)
+		}
+
+	case OpGet:
+		v, err := a.Store.Get(ctx, req.Name)
+		switch err.(type) {
+		case nil:
+			resp.Value, resp.Locked = v, true
+		case locker.LockNotFound:
+		default:
+			return fail(err)
+		}
+
+	default:
+		return fail(badRequest(fmt.Sprintf("unknown op %q", req.Op)))
+	}
+	return resp
+}
+
+func (a *Agent) acquire(ctx context.Context, c *conn, name, value string) error {
+	a.mu.Lock()
+	h, ok := a.locks[name]
+	a.mu.Unlock()
+	if ok && (h.owner != c || h.value != value) {
+		// held by another process of the host
+		return locker.LockDenied{}
+	}
+
+	if err := a.Store.AcquireOrFreshenLock(ctx, name, value); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if h, ok := a.locks[name]; ok && h.owner != c {
+		return locker.LockDenied{}
+	}
+	a.locks[name] = &hold{value: value, owner: c}
+	return nil
+}
+
+func (a *Agent) release(ctx context.Context, c *conn, name string) error {
+	a.mu.Lock()
+	h, ok := a.locks[name]
+	if ok && h.owner == c {
+		delete(a.locks, name)
+	}
+	a.mu.Unlock()
+
+	switch {
+	case !ok:
+		return locker.LockNotFound{}
+	case h.owner != c:
+		return locker.LockDenied{}
+	}
+	return a.delete(ctx, name, h.value)
+}
+
+// releaseAll releases the locks matching match.
+func (a *Agent) releaseAll(match func(*hold) bool) {
+	released := make(map[string]string)
+
+	a.mu.Lock()
+	for name, h := range a.locks {
+		if match(h) {
+			released[name] = h.value
+			delete(a.locks, name)
+		}
+	}
+	a.mu.Unlock()
+
+	ctx := context.Background()
+	for name, value := range released {
+		a.delete(ctx, name, value)
+	}
+}
+
+// delete removes the named lock from the Store if value still holds it,
+// atomically if the Store is a locker.CompareAndDeleter. Other Stores are
+// asked for the value first.
+func (a *Agent) delete(ctx context.Context, name, value string) error {
+	if deleter, ok := a.Store.(locker.CompareAndDeleter); ok {
+		switch err := deleter.CompareAndDelete(ctx, name, value); err.(type) {
+		case locker.LockDenied, locker.LockNotFound:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	v, err := a.Store.Get(ctx, name)
+	switch err.(type) {
+	case nil:
+		if v != value {
+			return nil
+		}
+		return a.Store.Delete(ctx, name)
+	case locker.LockNotFound:
+		return nil
+	}
+	return err
+}
+
+// refresh keeps the held locks fresh in the Store, and tells their
+// holders about the ones it lost.
+func (a *Agent) refresh() {
+	defer close(a.done)
+
+	interval := a.Refresh
+	if interval <= 0 {
+		interval = time.Second
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-a.quit:
+			return
+		case <-tick.C:
+		}
+
+		held := make(map[string]*hold)
+		a.mu.Lock()
+		for name, h := range a.locks {
+			held[name] = h
+		}
+		a.mu.Unlock()
+
+		ctx := context.Background()
+		for name, h := range held {
+			if _, denied := a.Store.AcquireOrFreshenLock(ctx, name, h.value).(locker.LockDenied); !denied {
+				continue
+			}
+
+			a.mu.Lock()
+			lost := a.locks[name] == h
+			if lost {
+				delete(a.locks, name)
+			}
+			a.mu.Unlock()
+			if lost {
+				h.owner.send(Message{Op: OpLost, Name: name, Value: h.value})
+			}
+		}
+	}
+}
+
+func (c *conn) send(m Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enc.Encode(m)
+}
+
+// defaultValue is the value of a lock taken without one.
+func (c *conn) defaultValue() string {
+	host, _ := os.Hostname()
+	if c.peer.PID == 0 {
+		return fmt.Sprintf("%s:%p", host, c)
+	}
+	return fmt.Sprintf("%s:%d", host, c.peer.PID)
+}
+
+type badRequest string
+
+func (e badRequest) Error() string {
+	return string(e)
+}
+
+// toError maps the errors of the Store onto protocol errors.
+func toError(err error) (string, string) {
+	switch err.(type) {
+	case badRequest:
+		return err.Error(), CodeBadRequest
+	case locker.LockDenied:
+		return "lock is held by somebody else", CodeDenied
+	case locker.LockNotFound:
+		return "lock isn't held", CodeNotFound
+	}
+	return err.Error(), CodeStore
+}
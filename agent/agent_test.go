@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+func newTestAgent(t *testing.T) (string, *locker.MemoryStore) {
+	dir, err := ioutil.TempDir("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "locker.sock")
+
+	store := &locker.MemoryStore{TTL: 1}
+	a := New(store)
+	a.Refresh = 100 * time.Millisecond
+	go a.ListenAndServe(path)
+	t.Cleanup(func() {
+		a.Close()
+		os.RemoveAll(dir)
+	})
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return path, store
+}
+
+func dial(t *testing.T, path string) *Store {
+	s, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// waitFor polls cond until it holds, or fails the test after a while.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("Timeout waiting for %s", what)
+}
+
+func TestAcquireRelease(t *testing.T) {
+	path, backing := newTestAgent(t)
+	ctx := context.Background()
+	first, second := dial(t, path), dial(t, path)
+
+	if err := first.AcquireOrFreshenLock(ctx, "job", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := second.AcquireOrFreshenLock(ctx, "job", "a").(locker.LockDenied); !ok {
+		t.Error("Expected a lock held by another process to be denied, even with its value")
+	}
+	if v, err := second.Get(ctx, "job"); err != nil || v != "a" {
+		t.Errorf("Get: %q %v", v, err)
+	}
+	if _, ok := second.Delete(ctx, "job").(locker.LockDenied); !ok {
+		t.Error("Expected Delete of another process's lock to be denied")
+	}
+
+	// held past the TTL of the store, the agent keeps it fresh
+	time.Sleep(1500 * time.Millisecond)
+	if v, _ := backing.Get(ctx, "job"); v != "a" {
+		t.Errorf("Expected the agent to keep the lock fresh, got %q", v)
+	}
+
+	if err := first.Delete(ctx, "job"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Get(ctx, "job"); err == nil {
+		t.Error("Expected the lock to be released")
+	}
+	if err := second.AcquireOrFreshenLock(ctx, "job", "b"); err != nil {
+		t.Errorf("Expected the lock to be free, got %v", err)
+	}
+}
+
+func TestReleaseOnClose(t *testing.T) {
+	path, backing := newTestAgent(t)
+	ctx := context.Background()
+	s := dial(t, path)
+
+	s.AcquireOrFreshenLock(ctx, "job", "a")
+	s.Close()
+
+	waitFor(t, "the lock to be released", func() bool {
+		_, err := backing.Get(ctx, "job")
+		return err != nil
+	})
+	if _, err := s.Get(ctx, "job"); err == nil {
+		t.Error("Expected requests to fail once the Store is closed")
+	}
+}
+
+func TestLost(t *testing.T) {
+	path, backing := newTestAgent(t)
+	ctx := context.Background()
+	s := dial(t, path)
+
+	s.AcquireOrFreshenLock(ctx, "job", "a")
+	backing.Delete(ctx, "job")
+	backing.AcquireOrFreshenLock(ctx, "job", "b")
+
+	select {
+	case name := <-s.Lost():
+		if name != "job" {
+			t.Errorf("Expected job to be lost, got %q", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the lock to be lost")
+	}
+}
+
+func TestProcessDeath(t *testing.T) {
+	path, backing := newTestAgent(t)
+	ctx := context.Background()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "AGENT_HELPER_SOCKET="+path)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// the helper says when it holds the lock
+	out.Read(make([]byte, 1))
+
+	v, err := backing.Get(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "linux" && !strings.HasSuffix(v, fmt.Sprintf(":%d", cmd.Process.Pid)) {
+		t.Errorf("Expected the value to name the pid of the process, got %q", v)
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+	waitFor(t, "the lock of the dead process to be released", func() bool {
+		_, err := backing.Get(ctx, "job")
+		return err != nil
+	})
+}
+
+// TestHelperProcess takes a lock without a value and sleeps, for
+// TestProcessDeath to kill it.
+func TestHelperProcess(t *testing.T) {
+	path := os.Getenv("AGENT_HELPER_SOCKET")
+	if path == "" {
+		return
+	}
+
+	s, err := Dial(path)
+	if err != nil {
+		os.Exit(1)
+	}
+	if _, err := s.call(context.Background(), Message{Op: OpAcquire, Name: "job"}); err != nil {
+		os.Exit(1)
+	}
+	os.Stdout.Write([]byte("x"))
+	time.Sleep(time.Minute)
+}
+
+func TestListenAndServeStaleSocket(t *testing.T) {
+	path, _ := newTestAgent(t)
+
+	// another agent is serving it
+	if err := New(&locker.MemoryStore{}).ListenAndServe(path); err == nil {
+		t.Fatal("Expected a socket served by another agent not to be taken over")
+	}
+	dial(t, path)
+
+	// left behind by an agent which is gone
+	stale := filepath.Join(filepath.Dir(path), "stale.sock")
+	l, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	a := New(&locker.MemoryStore{})
+	go a.ListenAndServe(stale)
+	defer a.Close()
+	waitFor(t, "the stale socket to be served", func() bool {
+		c, err := net.Dial("unix", stale)
+		if err == nil {
+			c.Close()
+		}
+		return err == nil
+	})
+}
+
+// takenStore has its locks taken by "b" as soon as they're released, as
+// though their lease ran out right then.
+type takenStore struct {
+	locker.MemoryStore
+}
+
+func (s *takenStore) CompareAndDelete(ctx context.Context, name, value string) error {
+	if v, err := s.MemoryStore.Get(ctx, name); err == nil {
+		s.MemoryStore.Transfer(ctx, name, v, "b")
+	}
+	return s.MemoryStore.CompareAndDelete(ctx, name, value)
+}
+
+func TestDeleteChangedHands(t *testing.T) {
+	ctx := context.Background()
+	store := &takenStore{}
+	a := New(store)
+
+	store.AcquireOrFreshenLock(ctx, "job", "a")
+	if err := a.delete(ctx, "job", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.MemoryStore.Get(ctx, "job"); v != "b" {
+		t.Errorf("Expected the new holder's lock to be left alone, got %q", v)
+	}
+}
@@ -0,0 +1,28 @@
+package agent
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerOf reads the credentials of the process at the other end of a Unix
+// socket.
+func peerOf(c net.Conn) Peer {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return Peer{}
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return Peer{}
+	}
+
+	var cred *syscall.Ucred
+	raw.Control(func(fd uintptr) {
+		cred, err = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil || cred == nil {
+		return Peer{}
+	}
+	return Peer{PID: int(cred.Pid), UID: int(cred.Uid), GID: int(cred.Gid)}
+}
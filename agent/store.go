@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// ErrClosed is returned by the requests of a Store whose connection to
+// the agent is closed.
+var ErrClosed = errors.New("agent: connection closed")
+
+// Store is a locker Store served by the agent of the host. Locks taken
+// through it are held until they're deleted or the Store is closed, and
+// don't expire otherwise; the agent keeps them fresh.
+//
+//	store, err := agent.Dial("/run/locker.sock")
+//	client := locker.Client{Store: store}
+//
+// Delete only releases locks held through the same Store, it answers
+// LockDenied for the locks of other processes.
+type Store struct {
+	conn net.Conn
+	lost chan string
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	nextID  uint64
+	pending map[uint64]chan Message
+	err     error
+}
+
+// Dial connects to the agent listening on the Unix socket at path.
+func Dial(path string) (*Store, error) {
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		conn:    c,
+		lost:    make(chan string, 16),
+		enc:     json.NewEncoder(c),
+		pending: make(map[uint64]chan Message),
+	}
+	go s.read()
+	return s, nil
+}
+
+// Get returns the value of a lock. LockNotFound will be returned if a
+// lock with the name isn't held.
+func (s *Store) Get(ctx context.Context, name string) (string, error) {
+	resp, err := s.call(ctx, Message{Op: OpGet, Name: name})
+	if err != nil {
+		return "", err
+	}
+	if !resp.Locked {
+		return "", locker.LockNotFound{}
+	}
+	return resp.Value, nil
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is.
+func (s *Store) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	_, err := s.call(ctx, Message{Op: OpAcquire, Name: name, Value: value})
+	return err
+}
+
+// Delete releases the named lock, if it's held through the Store.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	_, err := s.call(ctx, Message{Op: OpRelease, Name: name})
+	if _, ok := err.(locker.LockNotFound); ok {
+		return nil
+	}
+	return err
+}
+
+// Lost receives the names of the locks the agent lost in its Store while
+// they were held through this Store. Names are dropped if nobody
+// receives them.
+func (s *Store) Lost() <-chan string {
+	return s.lost
+}
+
+// Close closes the connection to the agent, which releases every lock
+// held through the Store.
+func (s *Store) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Store) call(ctx context.Context, req Message) (Message, error) {
+	done := make(chan Message, 1)
+
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return Message{}, s.err
+	}
+	s.nextID++
+	req.ID = s.nextID
+	s.pending[req.ID] = done
+	err := s.enc.Encode(req)
+	s.mu.Unlock()
+	if err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case resp, ok := <-done:
+		if !ok {
+			return Message{}, ErrClosed
+		}
+		return resp, fromError(resp)
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.pending, req.ID)
+		s.mu.Unlock()
+		return Message{}, ctx.Err()
+	}
+}
+
+// read hands the responses of the agent to their requests.
+func (s *Store) read() {
+	lines := bufio.NewScanner(s.conn)
+	for lines.Scan() {
+		var m Message
+		if err := json.Unmarshal(lines.Bytes(), &m); err != nil {
+			continue
+		}
+
+		if m.Op == OpLost && m.ID == 0 {
+			select {
+			case s.lost <- m.Name:
+			default:
+			}
+			continue
+		}
+
+		s.mu.Lock()
+		done, ok := s.pending[m.ID]
+		delete(s.pending, m.ID)
+		s.mu.Unlock()
+		if ok {
+			done <- m
+		}
+	}
+
+	s.mu.Lock()
+	s.err = ErrClosed
+	for id, done := range s.pending {
+		close(done)
+		delete(s.pending, id)
+	}
+	s.mu.Unlock()
+}
+
+// fromError maps protocol errors back onto the errors of locker.
+func fromError(m Message) error {
+	switch m.Code {
+	case "":
+		return nil
+	case CodeDenied:
+		return locker.LockDenied{}
+	case CodeNotFound:
+		return locker.LockNotFound{}
+	}
+	return errors.New("agent: " + m.Error)
+}
@@ -0,0 +1,259 @@
+package locker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Breaker is a circuit breaker around the Store of a Client. Once the
+// Store has failed Failures operations in a row, the breaker opens: the
+// Client's calls fail straight away with StoreUnavailable rather than
+// pile up on a struggling Store. After Cooldown it's half-open, and lets
+// a single operation through to probe the Store; the breaker closes if
+// it succeeds and opens again if it fails.
+//
+//     client.Breaker = &locker.Breaker{Failures: 5, Cooldown: 10 * time.Second}
+//     client.Bulkhead = &locker.Bulkhead{MaxInFlight: 32}
+//
+// Denied locks and missing locks are answers, not failures. A Breaker
+// can be shared by the Clients of a Store.
+type Breaker struct {
+	// Failures is the number of failures in a row which open the
+	// breaker. Default: 5.
+	Failures int
+
+	// Cooldown is how long the breaker stays open before it probes the
+	// Store. Default: 5s.
+	Cooldown time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	opened   time.Time
+	probing  bool
+}
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets operations through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails operations straight away.
+	BreakerOpen
+	// BreakerHalfOpen lets one operation through to probe the Store.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("breaker(%d)", int(s))
+}
+
+// State returns the state of the breaker.
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// allow tells whether an operation can go through at now. It moves an
+// open breaker whose cooldown is over to half-open, and makes the
+// operation its probe.
+func (b *Breaker) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.opened) < b.cooldown() {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// record records the outcome of an operation allow let through, and
+// returns the state the breaker moved to, if it changed.
+func (b *Breaker) record(now time.Time, failed bool) (BreakerState, bool) {
+	if b == nil {
+		return BreakerClosed, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from := b.state
+	b.probing = false
+	switch {
+	case !failed:
+		b.state, b.failures = BreakerClosed, 0
+	case b.state == BreakerHalfOpen:
+		b.state, b.opened = BreakerOpen, now
+	default:
+		b.failures++
+		if b.failures >= b.threshold() {
+			b.state, b.opened = BreakerOpen, now
+		}
+	}
+	return b.state, b.state != from
+}
+
+// abandon records that an operation allow let through ended without an
+// outcome.
+func (b *Breaker) abandon() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *Breaker) threshold() int {
+	if b.Failures <= 0 {
+		return 5
+	}
+	return b.Failures
+}
+
+func (b *Breaker) cooldown() time.Duration {
+	if b.Cooldown <= 0 {
+		return 5 * time.Second
+	}
+	return b.Cooldown
+}
+
+// Bulkhead limits the Store operations of a Client in flight at once, so
+// a slow Store doesn't collect an unbounded number of them. Operations
+// wait up to MaxWait for one of the others to finish, and then fail with
+// StoreUnavailable. A Bulkhead can be shared by several Clients.
+type Bulkhead struct {
+	// MaxInFlight is the most operations in flight at once.
+	MaxInFlight int
+
+	// MaxWait is how long an operation waits for its turn. Default: it
+	// doesn't wait.
+	MaxWait time.Duration
+
+	once  sync.Once
+	slots chan struct{}
+}
+
+// InFlight returns the number of operations in flight.
+func (b *Bulkhead) InFlight() int {
+	if b == nil {
+		return 0
+	}
+	b.init()
+	return len(b.slots)
+}
+
+// enter takes a slot for an operation, and tells whether it got one
+// before MaxWait had passed or ctx was done.
+func (b *Bulkhead) enter(ctx context.Context, clock Clock) bool {
+	if b == nil || b.MaxInFlight <= 0 {
+		return true
+	}
+	b.init()
+
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if b.MaxWait <= 0 {
+		return false
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	case <-clock.After(b.MaxWait):
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// leave gives the slot of an operation back.
+func (b *Bulkhead) leave() {
+	if b == nil || b.MaxInFlight <= 0 {
+		return
+	}
+	<-b.slots
+}
+
+func (b *Bulkhead) init() {
+	b.once.Do(func() {
+		b.slots = make(chan struct{}, b.MaxInFlight)
+	})
+}
+
+// guard makes the Store operation f through the Bulkhead and the
+// Breaker of the Client.
+func (c Client) guard(ctx context.Context, op, name string, f func(ctx context.Context) error) error {
+	if op == "Watch" {
+		// watches last as long as their callers want: they don't take
+		// a slot, nor probe the Store, but fail while it's unavailable
+		if c.Breaker.State() != BreakerClosed {
+			return StoreUnavailable{op, name}
+		}
+		return f(ctx)
+	}
+
+	if !c.Bulkhead.enter(ctx, c.clock()) {
+		return StoreUnavailable{op, name}
+	}
+	defer c.Bulkhead.leave()
+
+	if !c.Breaker.allow(c.clock().Now()) {
+		return StoreUnavailable{op, name}
+	}
+	err := f(ctx)
+	if err == context.Canceled {
+		// the caller gave up, which says nothing about the Store
+		c.Breaker.abandon()
+		return err
+	}
+
+	failed := true
+	switch err.(type) {
+	case nil, LockDenied, LockNotFound:
+		failed = false
+	}
+	if state, changed := c.Breaker.record(c.clock().Now(), failed); changed {
+		level := LevelInfo
+		if state == BreakerOpen {
+			level = LevelWarn
+		}
+		c.logger().Log(level, "store breaker "+state.String(), Field{FieldOp, op}, Field{FieldKey, name})
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker/clocktest"
+)
+
+// flakyStore is a MemoryStore which can be taken down, and made to hold
+// its Gets until they're let go.
+type flakyStore struct {
+	MemoryStore
+
+	mu    sync.Mutex
+	down  bool
+	calls int
+	hold  chan struct{}
+}
+
+func (s *flakyStore) Get(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	s.calls++
+	down, hold := s.down, s.hold
+	s.mu.Unlock()
+
+	if hold != nil {
+		<-hold
+	}
+	if down {
+		return "", errors.New("down")
+	}
+	return s.MemoryStore.Get(ctx, name)
+}
+
+func (s *flakyStore) set(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.down = down
+}
+
+func (s *flakyStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func TestBreaker(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &flakyStore{down: true}
+	breaker := &Breaker{Failures: 3, Cooldown: 10 * time.Second}
+	client := Client{Store: store, Clock: clock, Breaker: breaker}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get(name); err == nil || err == (StoreUnavailable{"Get", name}) {
+			t.Fatalf("expected the store's error, got %v", err)
+		}
+	}
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected the breaker to open, it's %s", breaker.State())
+	}
+	if _, err := client.Get(name); err != (StoreUnavailable{"Get", name}) {
+		t.Fatalf("expected StoreUnavailable, got %v", err)
+	}
+	if store.count() != 3 {
+		t.Errorf("expected the store to be left alone once open, got %d calls", store.count())
+	}
+
+	// the probe fails, it opens again
+	clock.Advance(10 * time.Second)
+	if _, err := client.Get(name); err == (StoreUnavailable{"Get", name}) {
+		t.Fatal("expected a probe")
+	}
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected the breaker to open again, it's %s", breaker.State())
+	}
+	if _, err := client.Get(name); err != (StoreUnavailable{"Get", name}) {
+		t.Fatalf("expected StoreUnavailable, got %v", err)
+	}
+
+	// the probe succeeds, it closes
+	store.set(false)
+	clock.Advance(10 * time.Second)
+	if _, err := client.Get(name); err != (LockNotFound{name}) {
+		t.Fatalf("expected the probe to reach the store, got %v", err)
+	}
+	if breaker.State() != BreakerClosed {
+		t.Fatalf("expected the breaker to close, it's %s", breaker.State())
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &flakyStore{down: true}
+	breaker := &Breaker{Failures: 1, Cooldown: time.Second}
+	client := Client{Store: store, Clock: clock, Breaker: breaker}
+
+	client.Get(name)
+	clock.Advance(time.Second)
+
+	store.mu.Lock()
+	store.down, store.hold = false, make(chan struct{})
+	store.mu.Unlock()
+
+	probed := make(chan error)
+	go func() {
+		_, err := client.Get(name)
+		probed <- err
+	}()
+	for breaker.State() != BreakerHalfOpen {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := client.Get(name); err != (StoreUnavailable{"Get", name}) {
+		t.Errorf("expected StoreUnavailable while probing, got %v", err)
+	}
+
+	close(store.hold)
+	if err := <-probed; err != (LockNotFound{name}) {
+		t.Errorf("probe: %v", err)
+	}
+	if breaker.State() != BreakerClosed {
+		t.Errorf("expected the breaker to close, it's %s", breaker.State())
+	}
+}
+
+func TestBreakerIgnoresDenials(t *testing.T) {
+	store := &MemoryStore{}
+	client := Client{Store: store, Breaker: &Breaker{Failures: 1}}
+	store.AcquireOrFreshenLock(context.Background(), name, "a")
+
+	for i := 0; i < 3; i++ {
+		if err := client.Refresh(name, "b"); err != (LockDenied{name}) {
+			t.Fatalf("expected LockDenied, got %v", err)
+		}
+	}
+	if _, err := client.Get("missing"); err != (LockNotFound{"missing"}) {
+		t.Fatalf("expected LockNotFound, got %v", err)
+	}
+	if client.Breaker.State() != BreakerClosed {
+		t.Errorf("expected the breaker to stay closed, it's %s", client.Breaker.State())
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &flakyStore{hold: make(chan struct{})}
+	bulkhead := &Bulkhead{MaxInFlight: 2}
+	client := Client{Store: store, Clock: clock, Bulkhead: bulkhead}
+
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := client.Get(name)
+			done <- err
+		}()
+	}
+	for bulkhead.InFlight() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := client.Get(name); err != (StoreUnavailable{"Get", name}) {
+		t.Errorf("expected StoreUnavailable when full, got %v", err)
+	}
+
+	// with MaxWait, it waits for its turn
+	bulkhead.MaxWait = time.Second
+	go func() {
+		_, err := client.Get(name)
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	store.hold <- struct{}{}
+	store.hold <- struct{}{}
+	store.hold <- struct{}{}
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != (LockNotFound{name}) {
+			t.Errorf("expected the Gets to go through, got %v", err)
+		}
+	}
+	if bulkhead.InFlight() != 0 {
+		t.Errorf("expected the slots back, %d in flight", bulkhead.InFlight())
+	}
+}
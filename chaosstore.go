@@ -0,0 +1,244 @@
+package locker
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ChaosStore is a Store which wraps another one and injects faults into
+// its operations, to test how a service copes with slow stores, failing
+// stores and lost locks.
+//
+//     store := &locker.ChaosStore{
+//         Store:      &locker.MemoryStore{},
+//         Seed:       42,
+//         Latency:    50 * time.Millisecond,
+//         ErrorRates: map[string]float64{"AcquireOrFreshenLock": 0.1},
+//         ExpireRate: 0.05,
+//     }
+//
+// Every fault is decided by a random source seeded with Seed, in the
+// order the operations are made, so the same sequence of operations
+// meets the same faults on every run. Faults are reported as
+// ChaosFault errors.
+//
+// The operations are Get, AcquireOrFreshenLock, Delete,
+// CompareAndDelete, Transfer and List; the last two need the wrapped
+// Store to implement them.
+type ChaosStore struct {
+	// Store is the Store faults are injected into.
+	Store Store
+
+	// Seed seeds the schedule of faults.
+	Seed int64
+
+	// Latency is added to every operation, and up to Jitter more.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// ErrorRates is the fraction of the operations, by name, which fail
+	// without reaching the Store.
+	ErrorRates map[string]float64
+
+	// ExpireRate is the fraction of successful AcquireOrFreshenLock
+	// calls after which the lock is expired at once, as though its
+	// lease ran out before the next refresh.
+	ExpireRate float64
+
+	// StaleRate is the fraction of Gets answered with the value the
+	// previous Get of the lock saw, rather than the current one.
+	StaleRate float64
+
+	// PartitionRate is the fraction of operations which start a
+	// partition of PartitionCalls operations. Operations in a partition
+	// hang until PartitionHang has passed, or forever if it's zero, or
+	// until their context is done, and then fail.
+	PartitionRate  float64
+	PartitionCalls int
+	PartitionHang  time.Duration
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	lastRead  map[string]string
+	partition int
+	manual    bool
+}
+
+// Get returns the value of a lock. LockNotFound will be returned if a
+// lock with the name isn't held.
+func (s *ChaosStore) Get(ctx context.Context, name string) (string, error) {
+	if err := s.inject(ctx, "Get", name); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	last, seen := s.lastRead[name]
+	stale := s.chance(s.StaleRate)
+	s.mu.Unlock()
+	if stale && seen {
+		if last == "" {
+			return "", LockNotFound{name}
+		}
+		return last, nil
+	}
+
+	v, err := s.Store.Get(ctx, name)
+	switch err.(type) {
+	case nil, LockNotFound:
+		s.mu.Lock()
+		s.lastRead[name] = v
+		s.mu.Unlock()
+	}
+	return v, err
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is.
+func (s *ChaosStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	if err := s.inject(ctx, "AcquireOrFreshenLock", name); err != nil {
+		return err
+	}
+	if err := s.Store.AcquireOrFreshenLock(ctx, name, value); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	expire := s.chance(s.ExpireRate)
+	s.mu.Unlock()
+	if expire {
+		return s.Expire(ctx, name)
+	}
+	return nil
+}
+
+// Delete releases the named lock, regardless of who holds it.
+func (s *ChaosStore) Delete(ctx context.Context, name string) error {
+	if err := s.inject(ctx, "Delete", name); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, name)
+}
+
+// CompareAndDelete releases the named lock if it's held with value;
+// atomically if the wrapped Store is a CompareAndDeleter.
+func (s *ChaosStore) CompareAndDelete(ctx context.Context, name, value string) error {
+	if err := s.inject(ctx, "CompareAndDelete", name); err != nil {
+		return err
+	}
+	return compareAndDelete(ctx, s.Store, name, value)
+}
+
+// Transfer gives the named lock held by from to to, with a fresh TTL.
+// The wrapped Store has to be a Transferer.
+func (s *ChaosStore) Transfer(ctx context.Context, name, from, to string) error {
+	transferer, ok := s.Store.(Transferer)
+	if !ok {
+		return Unsupported{"Transfer"}
+	}
+	if err := s.inject(ctx, "Transfer", name); err != nil {
+		return err
+	}
+	return transferer.Transfer(ctx, name, from, to)
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values. The wrapped Store has to be a Lister.
+func (s *ChaosStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	lister, ok := s.Store.(Lister)
+	if !ok {
+		return nil, Unsupported{"List"}
+	}
+	if err := s.inject(ctx, "List", prefix); err != nil {
+		return nil, err
+	}
+	return lister.List(ctx, prefix)
+}
+
+// Expire expires the named lock now, whoever holds it, as though its
+// lease ran out.
+func (s *ChaosStore) Expire(ctx context.Context, name string) error {
+	return s.Store.Delete(ctx, name)
+}
+
+// Partition starts a partition which lasts until Heal is called.
+func (s *ChaosStore) Partition() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.manual = true
+}
+
+// Heal ends the partition, whether it was started by Partition or by
+// PartitionRate.
+func (s *ChaosStore) Heal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.manual = false
+	s.partition = 0
+}
+
+// inject decides the faults of an operation, and waits out its latency
+// or its partition. It returns the error the operation fails with, if
+// it does.
+func (s *ChaosStore) inject(ctx context.Context, op, name string) error {
+	s.mu.Lock()
+	s.init()
+	if start := s.chance(s.PartitionRate); start && s.partition == 0 {
+		s.partition = s.PartitionCalls
+	}
+	partitioned := s.manual || s.partition > 0
+	if s.partition > 0 {
+		s.partition--
+	}
+	delay := s.Latency
+	if s.Jitter > 0 {
+		delay += time.Duration(s.rand.Int63n(int64(s.Jitter)))
+	}
+	fail := s.chance(s.ErrorRates[op])
+	s.mu.Unlock()
+
+	if partitioned {
+		var hang <-chan time.Time
+		if s.PartitionHang > 0 {
+			hang = time.After(s.PartitionHang)
+		}
+		select {
+		case <-hang:
+		case <-ctx.Done():
+		}
+		return ChaosFault{op, name, "partition"}
+	}
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if fail {
+		return ChaosFault{op, name, "error"}
+	}
+	return nil
+}
+
+// chance draws whether something with the probability rate happens. The
+// caller must hold s.mu.
+func (s *ChaosStore) chance(rate float64) bool {
+	s.init()
+	// always draw, so the schedule doesn't depend on which rates are set
+	return s.rand.Float64() < rate
+}
+
+// init sets up the random source on first use. The caller must hold
+// s.mu.
+func (s *ChaosStore) init() {
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(s.Seed))
+		s.lastRead = make(map[string]string)
+	}
+}
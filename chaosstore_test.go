@@ -0,0 +1,130 @@
+package locker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestChaosStoreDeterministic(t *testing.T) {
+	run := func(seed int64) string {
+		store := &ChaosStore{
+			Store:      &MemoryStore{},
+			Seed:       seed,
+			ErrorRates: map[string]float64{"Get": 0.3, "AcquireOrFreshenLock": 0.3},
+			ExpireRate: 0.2,
+			StaleRate:  0.2,
+		}
+		ctx := context.Background()
+
+		var trace string
+		for i := 0; i < 50; i++ {
+			name := fmt.Sprintf("lock-%d", i%5)
+			err := store.AcquireOrFreshenLock(ctx, name, "a")
+			v, gerr := store.Get(ctx, name)
+			trace += fmt.Sprintf("%v %q %v\n", err, v, gerr)
+		}
+		return trace
+	}
+
+	if a, b := run(1), run(1); a != b {
+		t.Errorf("same seed, different faults:\n%s\n%s", a, b)
+	}
+	if a, b := run(1), run(2); a == b {
+		t.Errorf("different seeds, same faults:\n%s", a)
+	}
+}
+
+func TestChaosStoreErrors(t *testing.T) {
+	store := &ChaosStore{
+		Store:      &MemoryStore{},
+		ErrorRates: map[string]float64{"Delete": 1},
+	}
+	ctx := context.Background()
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Delete(ctx, name).(ChaosFault); !ok {
+		t.Error("expected Delete to fail")
+	}
+	if v, err := store.Get(ctx, name); v != "a" || err != nil {
+		t.Errorf("got %q, %v", v, err)
+	}
+}
+
+func TestChaosStoreExpire(t *testing.T) {
+	store := &ChaosStore{Store: &MemoryStore{}, ExpireRate: 1}
+	ctx := context.Background()
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, name); err == nil {
+		t.Error("expected the lock to have expired")
+	}
+}
+
+func TestChaosStoreStale(t *testing.T) {
+	inner := &MemoryStore{}
+	store := &ChaosStore{Store: inner}
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, name); err == nil {
+		t.Fatal("expected no lock")
+	}
+	inner.AcquireOrFreshenLock(ctx, name, "a")
+
+	store.StaleRate = 1
+	if _, err := store.Get(ctx, name); err == nil {
+		t.Error("expected the stale read to miss the lock")
+	}
+	store.StaleRate = 0
+	if v, _ := store.Get(ctx, name); v != "a" {
+		t.Errorf("got %q", v)
+	}
+}
+
+func TestChaosStorePartition(t *testing.T) {
+	store := &ChaosStore{Store: &MemoryStore{}}
+	store.Partition()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, ok := store.AcquireOrFreshenLock(ctx, name, "a").(ChaosFault); !ok {
+		t.Error("expected the partition to fail the call")
+	}
+	if took := time.Since(start); took < 50*time.Millisecond {
+		t.Errorf("partitioned call returned after %s", took)
+	}
+
+	store.Heal()
+	if err := store.AcquireOrFreshenLock(context.Background(), name, "a"); err != nil {
+		t.Error(err)
+	}
+
+	store = &ChaosStore{Store: &MemoryStore{}, PartitionRate: 1, PartitionCalls: 2, PartitionHang: time.Millisecond}
+	for i := 0; i < 4; i++ {
+		if _, ok := store.Delete(context.Background(), name).(ChaosFault); !ok {
+			t.Errorf("call %d got through the partition", i)
+		}
+	}
+}
+
+func TestChaosStoreLatency(t *testing.T) {
+	store := &ChaosStore{Store: &MemoryStore{}, Latency: 20 * time.Millisecond, Jitter: 10 * time.Millisecond}
+
+	start := time.Now()
+	store.Get(context.Background(), name)
+	if took := time.Since(start); took < 20*time.Millisecond {
+		t.Errorf("took %s", took)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.Get(ctx, name); err != context.Canceled {
+		t.Errorf("got %v", err)
+	}
+}
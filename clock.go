@@ -0,0 +1,31 @@
+package locker
+
+import "time"
+
+// Clock tells the time to Clients and MemoryStores. Tests can set a fake
+// one, such as package clocktest's, to make expiry, refreshing and
+// watch polling happen instantly and deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After sends the time on the returned channel once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock used when none is set.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func orRealClock(c Clock) Clock {
+	if c == nil {
+		return realClock{}
+	}
+	return c
+}
+
+func (c Client) clock() Clock {
+	return orRealClock(c.Clock)
+}
@@ -0,0 +1,63 @@
+package locker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker/clocktest"
+)
+
+func TestMemoryStoreClock(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &MemoryStore{TTL: 5, Clock: clock}
+	ctx := context.Background()
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(4 * time.Second)
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(4 * time.Second)
+	if v, err := store.Get(ctx, name); v != "a" || err != nil {
+		t.Fatalf("freshened lock: %q, %v", v, err)
+	}
+
+	clock.Advance(time.Second)
+	if _, err := store.Get(ctx, name); err == nil {
+		t.Error("expected the lock to have expired")
+	}
+	if err := store.AcquireOrFreshenLock(ctx, name, "b"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWatchClock(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &MemoryStore{TTL: 5, Clock: clock}
+	client := Client{Store: store, Clock: clock}
+
+	if err := store.AcquireOrFreshenLock(context.Background(), name, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	valueChanges := make(chan string)
+	stop := make(chan bool)
+	defer close(stop)
+	go client.Watch(name, valueChanges, stop)
+
+	if v := <-valueChanges; v != "a" {
+		t.Fatalf("got %q", v)
+	}
+
+	// nobody freshens the lock, it expires between two polls
+	clock.BlockUntil(1)
+	clock.Advance(3 * time.Second)
+	clock.BlockUntil(1)
+	clock.Advance(3 * time.Second)
+	if v := <-valueChanges; v != "" {
+		t.Errorf("got %q, expected the lock to have expired", v)
+	}
+}
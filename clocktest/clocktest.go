@@ -0,0 +1,103 @@
+// Package clocktest provides a fake locker.Clock, whose time only moves
+// when a test advances it.
+//
+//	clock := clocktest.New(time.Now())
+//	store := &locker.MemoryStore{TTL: 5, Clock: clock}
+//	client := locker.Client{Store: store, Clock: clock}
+//
+//	client.Lock("job", "a", quit)
+//	clock.Advance(5 * time.Second) // the lock has expired
+//
+// Code waiting on the clock runs in other goroutines; BlockUntil waits
+// for them to be waiting before the test advances the clock past them.
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock. Create it with New.
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	until time.Time
+	c     chan time.Time
+}
+
+// New creates a Clock set to now.
+func New(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After sends the time on the returned channel once the clock has been
+// advanced by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{c.now.Add(d), ch})
+	c.cond.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the Afters which are due
+// on the way, earliest first.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].until.Before(c.waiters[j].until)
+	})
+	for len(c.waiters) > 0 && !c.waiters[0].until.After(end) {
+		w := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		c.now = w.until
+		w.c <- w.until
+	}
+	c.now = end
+	c.cond.Broadcast()
+}
+
+// Waiters returns how many Afters are waiting for the clock.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n Afters are waiting for the clock.
+// Afters which were abandoned, by a select taking another case, still
+// count until the clock passes them.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
@@ -0,0 +1,62 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdvance(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(start)
+
+	late := c.After(3 * time.Second)
+	early := c.After(time.Second)
+	if n := c.Waiters(); n != 2 {
+		t.Fatalf("waiters: %d", n)
+	}
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-early:
+		t.Fatal("fired early")
+	default:
+	}
+
+	c.Advance(time.Second)
+	if got := <-early; !got.Equal(start.Add(time.Second)) {
+		t.Errorf("fired at %s", got)
+	}
+	if now := c.Now(); !now.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("now: %s", now)
+	}
+
+	c.Advance(2 * time.Second)
+	if got := <-late; !got.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("fired at %s", got)
+	}
+	if n := c.Waiters(); n != 0 {
+		t.Errorf("waiters: %d", n)
+	}
+}
+
+func TestAfterZero(t *testing.T) {
+	c := New(time.Now())
+	select {
+	case <-c.After(0):
+	default:
+		t.Error("After(0) didn't fire")
+	}
+}
+
+func TestBlockUntil(t *testing.T) {
+	c := New(time.Now())
+	fired := make(chan struct{})
+	go func() {
+		<-c.After(time.Minute)
+		close(fired)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	<-fired
+}
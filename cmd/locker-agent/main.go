@@ -0,0 +1,95 @@
+// Command locker-agent holds locks on behalf of the processes of a host,
+// so they share its one connection to the Store instead of each opening
+// their own. Processes talk to it over a Unix socket, see package agent,
+// and their locks are released when they exit.
+//
+//	locker-agent --socket /run/locker.sock --store etcd --endpoints http://10.0.0.1:2379
+//
+// Flags can also be set through the environment:
+//
+//	--socket     LOCKER_SOCKET     path of the Unix socket to serve on
+//	--endpoints  LOCKER_ENDPOINTS  comma separated etcd endpoints
+//	--store      LOCKER_STORE      etcd, etcdv2 or file
+//	--dir        LOCKER_DIR        lock directory of the file store
+//	--ttl        LOCKER_TTL        time-to-live of locks in the store in seconds
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/agent"
+)
+
+func main() {
+	var (
+		socket    = flag.String("socket", env("LOCKER_SOCKET", "/run/locker.sock"), "path of the Unix socket to serve on [LOCKER_SOCKET]")
+		endpoints = flag.String("endpoints", env("LOCKER_ENDPOINTS", "http://127.0.0.1:2379"), "comma separated etcd endpoints [LOCKER_ENDPOINTS]")
+		store     = flag.String("store", env("LOCKER_STORE", "etcd"), "store to use: etcd, etcdv2 or file [LOCKER_STORE]")
+		dir       = flag.String("dir", env("LOCKER_DIR", os.TempDir()+"/locker"), "lock directory of the file store [LOCKER_DIR]")
+		ttl       = flag.Int64("ttl", envInt("LOCKER_TTL", 5), "time-to-live of locks in the store in seconds [LOCKER_TTL]")
+	)
+	flag.Parse()
+
+	s, err := newStore(*store, strings.Split(*endpoints, ","), *dir, *ttl)
+	if err != nil {
+		log.Fatalf("locker-agent: %s", err)
+	}
+
+	a := agent.New(s)
+	a.Refresh = time.Duration(*ttl) * time.Second / 3
+
+	// the locks are released by Close, which has to be done before
+	// exiting
+	closed := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		a.Close()
+		close(closed)
+	}()
+
+	log.Printf("locker-agent: serving on %s", *socket)
+	if err := a.ListenAndServe(*socket); err != nil {
+		log.Fatalf("locker-agent: %s", err)
+	}
+	<-closed
+	os.Remove(*socket)
+}
+
+func newStore(kind string, machines []string, dir string, ttl int64) (locker.Store, error) {
+	switch kind {
+	case "etcd":
+		client, err := locker.New(machines, 5, ttl, context.Background())
+		return client.Store, err
+	case "etcdv2":
+		return locker.NewV2(machines, 5, ttl, context.Background()).Store, nil
+	case "file":
+		return fileStore(dir, ttl)
+	}
+	return nil, fmt.Errorf("unknown store %q", kind)
+}
+
+func env(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return v
+	}
+	return def
+}
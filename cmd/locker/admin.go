@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// adminOptions are the flags of the admin commands.
+type adminOptions struct {
+	reason   string
+	holder   string
+	dryRun   bool
+	auditLog string
+}
+
+// adminFlags returns the flags of an admin command, which registers them
+// on fresh adminOptions and binds run to those.
+func adminFlags(run func(opts *adminOptions, cfg *config, args []string, out *printer) error) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		opts := &adminOptions{}
+
+		fs.StringVar(&opts.reason, "reason", "", "why the change is made, it's recorded with it (required)")
+		fs.StringVar(&opts.holder, "holder", "", "only change the lock while it's held by this value")
+		fs.BoolVar(&opts.dryRun, "dry-run", false, "print the changes without making them")
+		fs.StringVar(&opts.auditLog, "audit-log", env("LOCKER_AUDIT_LOG", ""), "file the changes are recorded in, stderr if empty [LOCKER_AUDIT_LOG]")
+		return func(cfg *config, args []string, out *printer) error {
+			return run(opts, cfg, args, out)
+		}
+	}
+}
+
+// admin creates the Admin making the changes of an admin command. closeLog
+// closes the audit log.
+func (opts *adminOptions) admin(cfg *config) (admin locker.Admin, closeLog func(), err error) {
+	if opts.reason == "" {
+		return admin, nil, usageError("a --reason is required")
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return admin, nil, err
+	}
+	admin = locker.Admin{Store: client.Store, Audit: &locker.JSONAuditor{W: os.Stderr}, DryRun: opts.dryRun}
+	closeLog = func() {}
+
+	if opts.auditLog != "" && !opts.dryRun {
+		f, err := os.OpenFile(opts.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return admin, nil, err
+		}
+		admin.Audit = &locker.JSONAuditor{W: f}
+		closeLog = func() { f.Close() }
+	}
+	return admin, closeLog, nil
+}
+
+func (opts *adminOptions) forceRelease(cfg *config, args []string, out *printer) error {
+	if len(args) != 1 {
+		return usageError("expected a lock name")
+	}
+	admin, closeLog, err := opts.admin(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	action, err := admin.ForceRelease(context.Background(), cfg.name(args[0]), opts.holder, opts.reason)
+	if err != nil {
+		return err
+	}
+	return out.actions(cfg, []locker.AdminAction{action})
+}
+
+func (opts *adminOptions) transfer(cfg *config, args []string, out *printer) error {
+	if len(args) != 2 {
+		return usageError("expected a lock name and the value to transfer it to")
+	}
+	admin, closeLog, err := opts.admin(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	action, err := admin.Transfer(context.Background(), cfg.name(args[0]), opts.holder, args[1], opts.reason)
+	if err != nil {
+		return err
+	}
+	return out.actions(cfg, []locker.AdminAction{action})
+}
+
+func (opts *adminOptions) revoke(cfg *config, args []string, out *printer) error {
+	if len(args) != 1 || args[0] == "" {
+		return usageError("expected a prefix")
+	}
+	admin, closeLog, err := opts.admin(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	actions, err := admin.Revoke(context.Background(), cfg.name(args[0]), opts.reason)
+	if printErr := out.actions(cfg, actions); err == nil {
+		err = printErr
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+func runLock(cfg *config, args []string, out *printer) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("expected a lock name and an optional value")
+	}
+	value := locker.DefaultValue
+	if len(args) == 2 {
+		value = args[1]
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return err
+	}
+
+	name := cfg.name(args[0])
+	report := client.Lock(name, value, nil)
+	if report.Err != nil {
+		return report.Err
+	}
+	if report.Msg != locker.Success {
+		return fmt.Errorf("%s is held by somebody else", args[0])
+	}
+
+	return out.lock(lockInfo{Name: args[0], Locked: true, Value: value})
+}
+
+func runUnlock(cfg *config, args []string, out *printer) error {
+	if len(args) != 1 {
+		return usageError("expected a lock name")
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return err
+	}
+
+	if err := client.Unlock(cfg.name(args[0]), nil); err != nil {
+		return err
+	}
+	return out.lock(lockInfo{Name: args[0]})
+}
+
+func runGet(cfg *config, args []string, out *printer) error {
+	if len(args) != 1 {
+		return usageError("expected a lock name")
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return err
+	}
+
+	v, err := client.Get(cfg.name(args[0]))
+	if err != nil {
+		return err
+	}
+	return out.value(v)
+}
+
+func runInspect(cfg *config, args []string, out *printer) error {
+	if len(args) != 1 {
+		return usageError("expected a lock name")
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return err
+	}
+
+	info := lockInfo{Name: args[0]}
+	v, err := client.Get(cfg.name(args[0]))
+	switch err.(type) {
+	case nil:
+		info.Locked = len(v) > 0
+		info.Value = v
+	case locker.LockNotFound:
+	default:
+		return err
+	}
+	return out.lock(info)
+}
+
+func runList(cfg *config, args []string, out *printer) error {
+	if len(args) > 1 {
+		return usageError("expected an optional prefix")
+	}
+	prefix := ""
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return err
+	}
+
+	locks, err := client.List(cfg.name(prefix))
+	if err != nil {
+		return err
+	}
+
+	var infos []lockInfo
+	for name, value := range locks {
+		infos = append(infos, lockInfo{Name: cfg.strip(name), Locked: true, Value: value})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return out.locks(infos)
+}
+
+func runWatch(cfg *config, args []string, out *printer) error {
+	if len(args) != 1 {
+		return usageError("expected a lock name")
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	changes := make(chan string)
+	quit := make(chan bool)
+	defer close(quit)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Watch(cfg.name(args[0]), changes, quit)
+	}()
+
+	for {
+		select {
+		case v := <-changes:
+			if err := out.lock(lockInfo{Name: args[0], Locked: v != "", Value: v}); err != nil {
+				return err
+			}
+		case err := <-done:
+			if err == nil {
+				err = errors.New("watch ended")
+			}
+			return err
+		case <-signals:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/agent"
+)
+
+// config is what the client is created from. The defaults come from the
+// environment, flags override them.
+type config struct {
+	endpoints string
+	store     string
+	dir       string
+	socket    string
+	ttl       int64
+	timeout   int64
+	namespace string
+	output    string
+}
+
+func defaultConfig() *config {
+	return &config{
+		endpoints: env("LOCKER_ENDPOINTS", "http://127.0.0.1:2379"),
+		store:     env("LOCKER_STORE", "etcd"),
+		dir:       env("LOCKER_DIR", os.TempDir()+"/locker"),
+		socket:    env("LOCKER_SOCKET", "/run/locker.sock"),
+		ttl:       envInt("LOCKER_TTL", 5),
+		timeout:   envInt("LOCKER_TIMEOUT", 5),
+		namespace: env("LOCKER_NAMESPACE", ""),
+		output:    env("LOCKER_OUTPUT", "table"),
+	}
+}
+
+// register adds the flags of the config to fs. They're registered on the
+// global flag set and on every command's, so they can go either side of
+// the command name.
+func (c *config) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.endpoints, "endpoints", c.endpoints, "comma separated etcd endpoints, or the lockerd URL [LOCKER_ENDPOINTS]")
+	fs.StringVar(&c.store, "store", c.store, "store to use: etcd, etcdv2, file, remote or agent [LOCKER_STORE]")
+	fs.StringVar(&c.dir, "dir", c.dir, "lock directory of the file store [LOCKER_DIR]")
+	fs.StringVar(&c.socket, "socket", c.socket, "socket of the locker-agent of the host [LOCKER_SOCKET]")
+	fs.Int64Var(&c.ttl, "ttl", c.ttl, "time-to-live of locks in seconds [LOCKER_TTL]")
+	fs.Int64Var(&c.timeout, "timeout", c.timeout, "dial timeout in seconds [LOCKER_TIMEOUT]")
+	fs.StringVar(&c.namespace, "namespace", c.namespace, "prefix of every lock name [LOCKER_NAMESPACE]")
+	fs.StringVar(&c.output, "output", c.output, "output format: table or json [LOCKER_OUTPUT]")
+}
+
+// client creates the locker client the config describes.
+func (c *config) client() (locker.Client, error) {
+	ctx := context.Background()
+	machines := strings.Split(c.endpoints, ",")
+
+	switch c.store {
+	case "etcd":
+		return locker.New(machines, c.timeout, c.ttl, ctx)
+	case "etcdv2":
+		return locker.NewV2(machines, c.timeout, c.ttl, ctx), nil
+	case "file":
+		store, err := fileStore(c.dir, c.ttl)
+		return locker.Client{Store: store}, err
+	case "remote":
+		return locker.NewRemote(machines[0], c.ttl, ctx), nil
+	case "agent":
+		// the locks are released when the command exits and closes the
+		// connection
+		store, err := agent.Dial(c.socket)
+		return locker.Client{Store: store}, err
+	}
+	return locker.Client{}, fmt.Errorf("unknown store %q", c.store)
+}
+
+// name returns the name of a lock within the namespace.
+func (c *config) name(name string) string {
+	if c.namespace == "" {
+		return name
+	}
+	return strings.TrimSuffix(c.namespace, "/") + "/" + name
+}
+
+// strip returns the name of a lock relative to the namespace.
+func (c *config) strip(name string) string {
+	if c.namespace == "" {
+		return name
+	}
+	return strings.TrimPrefix(name, strings.TrimSuffix(c.namespace, "/")+"/")
+}
+
+func env(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// execOptions are the flags of the exec command.
+type execOptions struct {
+	name  string
+	value string
+	grace time.Duration
+	wait  time.Duration
+}
+
+func execFlags(fs *flag.FlagSet) runFunc {
+	host, _ := os.Hostname()
+	opts := &execOptions{}
+
+	fs.StringVar(&opts.name, "name", "", "name of the lock to hold")
+	fs.StringVar(&opts.value, "value", fmt.Sprintf("%s/%d", host, os.Getpid()), "value of the lock, identifies the holder")
+	fs.DurationVar(&opts.grace, "grace", 10*time.Second, "time the command gets to exit after the lock is lost, before it's killed")
+	fs.DurationVar(&opts.wait, "wait", 0, "how long to wait for the lock if it's held, fail straight away by default")
+	return opts.run
+}
+
+// run runs a command while holding a lock, like flock(1). The lock is
+// freshened while the command runs; if it's lost the command is sent
+// SIGTERM, and killed once the grace period is over. The exit code of the
+// command is passed on.
+func (opts *execOptions) run(cfg *config, args []string, out *printer) error {
+	if opts.name == "" || len(args) == 0 {
+		return usageError("expected a lock name and a command")
+	}
+
+	client, err := cfg.client()
+	if err != nil {
+		return err
+	}
+	name := cfg.name(opts.name)
+
+	client.TTL = time.Duration(cfg.ttl) * time.Second
+	lock, err := acquire(client, name, opts.value, opts.wait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = out.w
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwarded...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	lost := lock.Context().Done()
+	var kill <-chan time.Time
+	for {
+		select {
+		case err := <-exited:
+			return exitStatus(err)
+
+		case sig := <-signals:
+			cmd.Process.Signal(sig)
+
+		case <-lost:
+			lost = nil
+			fmt.Fprintf(os.Stderr, "locker exec: lost lock %s: %s\n", opts.name, context.Cause(lock.Context()))
+			cmd.Process.Signal(syscall.SIGTERM)
+			kill = time.After(opts.grace)
+
+		case <-kill:
+			cmd.Process.Kill()
+		}
+	}
+}
+
+// acquire takes the lock, retrying until wait has passed if somebody
+// else holds it.
+func acquire(client locker.Client, name, value string, wait time.Duration) (*locker.Handle, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		lock, err := client.Acquire(name, value)
+		if _, denied := err.(locker.LockDenied); !denied {
+			return lock, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by somebody else", name)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// exitStatus turns the result of the command into the exit code to pass
+// on. A command killed by a signal exits with 128 plus the signal number,
+// like it would from a shell.
+func exitStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return exitCode(128 + int(status.Signal()))
+	}
+	return exitCode(exitErr.ExitCode())
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import "os"
+
+// forwarded are the signals passed on to the command, of which there's
+// only an interrupt off Unix.
+var forwarded = []os.Signal{os.Interrupt}
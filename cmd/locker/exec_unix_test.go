@@ -0,0 +1,61 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	store := locker.FileStore{Dir: dir}
+	args := []string{"--store", "file", "--dir", dir, "exec", "--name", "job", "--value", "me", "--"}
+
+	var stdout, stderr bytes.Buffer
+	code := run(append(args, "sh", "-c", "cat "+dir+"/job.lock; exit 3"), &stdout, &stderr)
+	if code != 3 {
+		t.Errorf("Expected the exit code of the command, got %d %s", code, stderr.String())
+	}
+	if !bytes.HasSuffix(stdout.Bytes(), []byte("\nme")) {
+		t.Errorf("Expected the lock to be held while the command runs, got %q", stdout.String())
+	}
+	if _, err := store.Get(context.Background(), "job"); err == nil {
+		t.Errorf("Expected the lock to be released, got %v", err)
+	}
+
+	store.AcquireOrFreshenLock(context.Background(), "job", "other")
+	if code := run(append(args, "true"), &stdout, &stderr); code != exitFail {
+		t.Errorf("Expected exec of a held lock to fail, got %d", code)
+	}
+	store.Delete(context.Background(), "job")
+}
+
+func TestExecLostLock(t *testing.T) {
+	dir := t.TempDir()
+	store := locker.FileStore{Dir: dir}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		store.Delete(context.Background(), "job")
+		store.AcquireOrFreshenLock(context.Background(), "job", "thief")
+	}()
+
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	code := run([]string{"--store", "file", "--dir", dir, "--ttl", "1", "exec", "--name", "job", "--grace", "100ms", "--", "sleep", "30"}, &stdout, &stderr)
+	if code != 128+15 {
+		t.Errorf("Expected the command to be terminated, got %d %s", code, stderr.String())
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the command to be terminated once the lock was lost")
+	}
+	if v, _ := store.Get(context.Background(), "job"); v != "thief" {
+		t.Errorf("Expected the new holder's lock to be left alone, got %q", v)
+	}
+}
@@ -0,0 +1,170 @@
+// Command locker manages locker locks from the command line, so
+// operators don't need to know how locks are laid out in etcd.
+//
+//	locker [flags] <command> [flags] [args]
+//
+// Commands:
+//
+//	lock <name> [value]   acquire a lock, it's held until its TTL runs out
+//	unlock <name>         release a lock, regardless of who holds it
+//	get <name>            print the value of a lock
+//	inspect <name>        print whether a lock is held, and by what value
+//	list [prefix]         print the held locks whose names start with prefix
+//	watch <name>          print the value of a lock whenever it changes
+//	exec --name <name> -- <command> [args]
+//	                      run a command while holding a lock
+//
+// Admin commands take locks away from wedged holders. They need a
+// --reason, which is recorded with the change in --audit-log, and take
+// --dry-run to print the changes without making them:
+//
+//	force-release <name>      release a lock held by somebody else
+//	transfer <name> <value>   hand a lock over to another value atomically
+//	revoke <prefix>           release every lock whose name starts with prefix
+//
+// Flags can also be set through the environment:
+//
+//	--endpoints  LOCKER_ENDPOINTS  comma separated etcd endpoints, or the lockerd URL
+//	--store      LOCKER_STORE      etcd, etcdv2, file, remote or agent
+//	--dir        LOCKER_DIR        lock directory of the file store
+//	--socket     LOCKER_SOCKET     socket of the locker-agent of the host
+//	--ttl        LOCKER_TTL        time-to-live of locks in seconds
+//	--timeout    LOCKER_TIMEOUT    dial timeout in seconds
+//	--namespace  LOCKER_NAMESPACE  prefix of every lock name
+//	--output     LOCKER_OUTPUT     table or json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitFail  = 1
+	exitUsage = 2
+)
+
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   runFunc
+
+	// flags, for a command which takes flags of its own, registers them
+	// on fs, and returns the run bound to them. It's used instead of run.
+	flags func(fs *flag.FlagSet) runFunc
+}
+
+// runFunc runs a command with the args left after its flags.
+type runFunc func(cfg *config, args []string, out *printer) error
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"lock", "<name> [value]", "acquire a lock, it's held until its TTL runs out", runLock, nil},
+		{"unlock", "<name>", "release a lock, regardless of who holds it", runUnlock, nil},
+		{"get", "<name>", "print the value of a lock", runGet, nil},
+		{"inspect", "<name>", "print whether a lock is held, and by what value", runInspect, nil},
+		{"list", "[prefix]", "print the held locks whose names start with prefix", runList, nil},
+		{"watch", "<name>", "print the value of a lock whenever it changes", runWatch, nil},
+		{"exec", "--name <name> -- <command> [args]", "run a command while holding a lock", nil, execFlags},
+		{"force-release", "--reason <reason> <name>", "release a lock held by somebody else", nil, adminFlags((*adminOptions).forceRelease)},
+		{"transfer", "--reason <reason> <name> <value>", "hand a lock over to another value atomically", nil, adminFlags((*adminOptions).transfer)},
+		{"revoke", "--reason <reason> <prefix>", "release every lock whose name starts with prefix", nil, adminFlags((*adminOptions).revoke)},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line args and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg := defaultConfig()
+
+	global := flag.NewFlagSet("locker", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { usage(global, stderr) }
+	cfg.register(global)
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+	if global.NArg() == 0 {
+		usage(global, stderr)
+		return exitUsage
+	}
+
+	name := global.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		fs := flag.NewFlagSet("locker "+cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "Usage: locker %s [flags] %s\n\n", cmd.name, cmd.args)
+			fs.PrintDefaults()
+		}
+		cfg.register(fs)
+		runCmd := cmd.run
+		if cmd.flags != nil {
+			runCmd = cmd.flags(fs)
+		}
+		if err := fs.Parse(global.Args()[1:]); err != nil {
+			return exitUsage
+		}
+
+		out, err := newPrinter(stdout, cfg.output)
+		if err == nil {
+			err = runCmd(cfg, fs.Args(), out)
+		}
+		switch err.(type) {
+		case nil:
+			return exitOK
+		case usageError:
+			fmt.Fprintf(stderr, "locker %s: %s\n", cmd.name, err)
+			fs.Usage()
+			return exitUsage
+		case exitCode:
+			return int(err.(exitCode))
+		default:
+			fmt.Fprintf(stderr, "locker %s: %s\n", cmd.name, err)
+			return exitFail
+		}
+	}
+
+	fmt.Fprintf(stderr, "locker: unknown command %q\n", name)
+	usage(global, stderr)
+	return exitUsage
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, "Usage: locker [flags] <command> [flags] [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-13s %-36s %s\n", cmd.name, cmd.args, cmd.usage)
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// usageError is returned by a command when it's called with the wrong
+// arguments.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// exitCode is returned by a command which has to exit with a specific
+// code, without anything being printed.
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	locker := func(args ...string) (string, int) {
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"--store", "file", "--dir", dir, "--namespace", "jobs"}, args...)
+		code := run(args, &stdout, &stderr)
+		return stdout.String() + stderr.String(), code
+	}
+
+	if out, code := locker("lock", "backup", "host-a"); code != exitOK {
+		t.Fatalf("lock: %d %s", code, out)
+	}
+	if out, code := locker("lock", "backup", "host-b"); code != exitFail {
+		t.Errorf("Expected a second lock to fail, got %d %s", code, out)
+	}
+	if out, code := locker("get", "backup"); code != exitOK || out != "host-a\n" {
+		t.Errorf("get: %d %q", code, out)
+	}
+	if out, code := locker("inspect", "--output", "json", "backup"); code != exitOK ||
+		strings.TrimSpace(out) != `{"name":"backup","locked":true,"value":"host-a"}` {
+		t.Errorf("inspect: %d %s", code, out)
+	}
+
+	locker("lock", "report")
+	if out, code := locker("--output", "json", "list"); code != exitOK ||
+		strings.TrimSpace(out) != `[{"name":"backup","locked":true,"value":"host-a"},{"name":"report","locked":true,"value":"ok"}]` {
+		t.Errorf("list: %d %s", code, out)
+	}
+
+	if out, code := locker("unlock", "backup"); code != exitOK {
+		t.Fatalf("unlock: %d %s", code, out)
+	}
+	if out, code := locker("get", "backup"); code != exitFail {
+		t.Errorf("Expected get of a released lock to fail, got %d %s", code, out)
+	}
+	if out, code := locker("list"); code != exitOK || out != "NAME    VALUE\nreport  ok\n" {
+		t.Errorf("list: %d %q", code, out)
+	}
+
+	if _, code := locker("get"); code != exitUsage {
+		t.Errorf("Expected usage error, got %d", code)
+	}
+	if _, code := locker("frobnicate"); code != exitUsage {
+		t.Errorf("Expected usage error, got %d", code)
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	dir := t.TempDir()
+	auditLog := dir + "/audit.log"
+	locker := func(args ...string) (string, int) {
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"--store", "file", "--dir", dir}, args...)
+		code := run(args, &stdout, &stderr)
+		return stdout.String() + stderr.String(), code
+	}
+
+	locker("lock", "jobs/a", "host-a")
+	locker("lock", "jobs/b", "host-b")
+
+	if _, code := locker("force-release", "jobs/a"); code != exitUsage {
+		t.Errorf("Expected a reason to be required, got %d", code)
+	}
+	if out, code := locker("transfer", "--reason", "failover", "--audit-log", auditLog, "jobs/a", "host-c"); code != exitOK ||
+		!strings.Contains(out, "transfer  jobs/a  host-a  host-c") {
+		t.Errorf("transfer: %d %q", code, out)
+	}
+	if out, _ := locker("get", "jobs/a"); out != "host-c\n" {
+		t.Errorf("Expected the lock to be transferred, got %q", out)
+	}
+	if out, code := locker("force-release", "--reason", "x", "--holder", "host-a", "--audit-log", auditLog, "jobs/a"); code != exitFail {
+		t.Errorf("Expected release of a lock with another holder to fail, got %d %q", code, out)
+	}
+
+	if out, code := locker("revoke", "--reason", "deploy", "--dry-run", "jobs/"); code != exitOK || strings.Count(out, "(dry run)") != 2 {
+		t.Errorf("dry run revoke: %d %q", code, out)
+	}
+	if out, code := locker("revoke", "--reason", "deploy", "--audit-log", auditLog, "jobs/"); code != exitOK {
+		t.Errorf("revoke: %d %q", code, out)
+	}
+	if out, _ := locker("list"); out != "NAME  VALUE\n" {
+		t.Errorf("Expected every lock to be revoked, got %q", out)
+	}
+
+	log, err := os.ReadFile(auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(log), `"reason"`); n != 3 {
+		t.Errorf("Expected 3 recorded changes, got %d:\n%s", n, log)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// printer writes the results of commands as a table or as JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "table":
+		return &printer{w: w}, nil
+	case "json":
+		return &printer{w: w, json: true}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// lockInfo is how a lock is printed.
+type lockInfo struct {
+	Name   string `json:"name"`
+	Locked bool   `json:"locked"`
+	Value  string `json:"value,omitempty"`
+}
+
+// lock prints a single lock. Tables get no header, so the output of
+// lock, get and watch can be used in scripts as it is.
+func (p *printer) lock(info lockInfo) error {
+	if p.json {
+		return json.NewEncoder(p.w).Encode(info)
+	}
+
+	state := "unlocked"
+	if info.Locked {
+		state = "locked"
+	}
+	_, err := fmt.Fprintf(p.w, "%s\t%s\t%s\n", info.Name, state, info.Value)
+	return err
+}
+
+// locks prints a list of locks.
+func (p *printer) locks(infos []lockInfo) error {
+	if p.json {
+		if infos == nil {
+			infos = []lockInfo{}
+		}
+		return json.NewEncoder(p.w).Encode(infos)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVALUE")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\n", info.Name, info.Value)
+	}
+	return tw.Flush()
+}
+
+// actions prints the changes made by an admin command.
+func (p *printer) actions(cfg *config, actions []locker.AdminAction) error {
+	for i := range actions {
+		actions[i].Name = cfg.strip(actions[i].Name)
+	}
+	if p.json {
+		if actions == nil {
+			actions = []locker.AdminAction{}
+		}
+		return json.NewEncoder(p.w).Encode(actions)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "OP\tNAME\tHOLDER\tTO")
+	for _, action := range actions {
+		op := action.Op
+		if action.DryRun {
+			op += " (dry run)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", op, action.Name, action.Holder, action.To)
+	}
+	return tw.Flush()
+}
+
+// value prints the value of a lock on its own.
+func (p *printer) value(v string) error {
+	if p.json {
+		return json.NewEncoder(p.w).Encode(v)
+	}
+	_, err := fmt.Fprintln(p.w, v)
+	return err
+}
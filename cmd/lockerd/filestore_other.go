@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// fileStore fails, there's no FileStore on this platform.
+func fileStore(dir string, ttl int64) (locker.Store, error) {
+	return nil, errors.New("the file store isn't supported on this platform")
+}
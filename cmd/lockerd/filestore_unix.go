@@ -0,0 +1,11 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import "github.com/PumpkinSeed/locker"
+
+// fileStore returns a FileStore keeping its locks in dir.
+func fileStore(dir string, ttl int64) (locker.Store, error) {
+	return locker.FileStore{Dir: dir, TTL: ttl}, nil
+}
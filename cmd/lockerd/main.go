@@ -0,0 +1,138 @@
+// Command lockerd serves the locker HTTP/JSON and gRPC APIs, so services
+// which can't link Go can take locks. See package server for the HTTP
+// API and lockerpb/locker.proto for the gRPC one.
+//
+//	lockerd --listen :7979 --store etcd --endpoints http://10.0.0.1:2379
+//
+// Flags can also be set through the environment:
+//
+//	--listen     LOCKERD_LISTEN    address to serve the API on
+//	--grpc       LOCKERD_GRPC      address to serve the gRPC API on, off if empty
+//	--endpoints  LOCKER_ENDPOINTS  comma separated etcd endpoints
+//	--store      LOCKER_STORE      etcd, etcdv2, file or memory
+//	--dir        LOCKER_DIR        lock directory of the file store
+//	--ttl        LOCKER_TTL        time-to-live of locks in the store in seconds
+//	--lease-ttl  LOCKERD_LEASE_TTL default lease time-to-live in seconds
+//	--max-ttl    LOCKERD_MAX_TTL   longest lease time-to-live a client can ask for
+//	--admin      LOCKERD_ADMIN     serve the admin operations
+//	--audit-log  LOCKERD_AUDIT_LOG file admin operations are recorded in, stderr if empty
+//	--origins    LOCKERD_ORIGINS   comma separated origins of other web pages allowed to open WebSockets
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/lockerpb"
+	"github.com/PumpkinSeed/locker/server"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	var (
+		listen    = flag.String("listen", env("LOCKERD_LISTEN", ":7979"), "address to serve the API on [LOCKERD_LISTEN]")
+		grpcAddr  = flag.String("grpc", env("LOCKERD_GRPC", ""), "address to serve the gRPC API on, off if empty [LOCKERD_GRPC]")
+		endpoints = flag.String("endpoints", env("LOCKER_ENDPOINTS", "http://127.0.0.1:2379"), "comma separated etcd endpoints [LOCKER_ENDPOINTS]")
+		store     = flag.String("store", env("LOCKER_STORE", "etcd"), "store to use: etcd, etcdv2, file or memory [LOCKER_STORE]")
+		dir       = flag.String("dir", env("LOCKER_DIR", os.TempDir()+"/locker"), "lock directory of the file store [LOCKER_DIR]")
+		ttl       = flag.Int64("ttl", envInt("LOCKER_TTL", 5), "time-to-live of locks in the store in seconds [LOCKER_TTL]")
+		leaseTTL  = flag.Int64("lease-ttl", envInt("LOCKERD_LEASE_TTL", 5), "default lease time-to-live in seconds [LOCKERD_LEASE_TTL]")
+		maxTTL    = flag.Int64("max-ttl", envInt("LOCKERD_MAX_TTL", 300), "longest lease time-to-live a client can ask for [LOCKERD_MAX_TTL]")
+		admin     = flag.Bool("admin", env("LOCKERD_ADMIN", "") == "true", "serve the admin operations [LOCKERD_ADMIN]")
+		auditLog  = flag.String("audit-log", env("LOCKERD_AUDIT_LOG", ""), "file admin operations are recorded in, stderr if empty [LOCKERD_AUDIT_LOG]")
+		origins   = flag.String("origins", env("LOCKERD_ORIGINS", ""), "comma separated origins of other web pages allowed to open WebSockets [LOCKERD_ORIGINS]")
+	)
+	flag.Parse()
+
+	s, err := newStore(*store, strings.Split(*endpoints, ","), *dir, *ttl)
+	if err != nil {
+		log.Fatalf("lockerd: %s", err)
+	}
+
+	srv := server.New(s)
+	srv.TTL = *leaseTTL
+	srv.MaxTTL = *maxTTL
+	srv.Refresh = time.Duration(*ttl) * time.Second / 3
+	srv.Admin = *admin
+	if *origins != "" {
+		srv.Origins = strings.Split(*origins, ",")
+	}
+	srv.Audit = &locker.JSONAuditor{W: os.Stderr}
+	if *auditLog != "" {
+		f, err := os.OpenFile(*auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			log.Fatalf("lockerd: %s", err)
+		}
+		defer f.Close()
+		srv.Audit = &locker.JSONAuditor{W: f}
+	}
+	defer srv.Close()
+
+	grpcServer := grpc.NewServer()
+	lockerpb.RegisterLockerServer(grpcServer, srv.GRPC())
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("lockerd: %s", err)
+		}
+		log.Printf("lockerd: serving gRPC on %s", *grpcAddr)
+		go grpcServer.Serve(lis)
+	}
+
+	httpServer := &http.Server{Addr: *listen, Handler: srv}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		grpcServer.GracefulStop()
+		httpServer.Shutdown(ctx)
+	}()
+
+	log.Printf("lockerd: serving on %s", *listen)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("lockerd: %s", err)
+	}
+}
+
+func newStore(kind string, machines []string, dir string, ttl int64) (locker.Store, error) {
+	switch kind {
+	case "etcd":
+		client, err := locker.New(machines, 5, ttl, context.Background())
+		return client.Store, err
+	case "etcdv2":
+		return locker.NewV2(machines, 5, ttl, context.Background()).Store, nil
+	case "file":
+		return fileStore(dir, ttl)
+	case "memory":
+		return &locker.MemoryStore{TTL: ttl}, nil
+	}
+	return nil, fmt.Errorf("unknown store %q", kind)
+}
+
+func env(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return v
+	}
+	return def
+}
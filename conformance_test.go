@@ -0,0 +1,79 @@
+package locker_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/etcdtest"
+	"github.com/PumpkinSeed/locker/server"
+	"github.com/PumpkinSeed/locker/storetest"
+)
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		return &locker.MemoryStore{TTL: ttl}
+	})
+}
+
+func TestQuorumStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		return &locker.QuorumStore{
+			Stores: []locker.Store{
+				&locker.MemoryStore{TTL: ttl},
+				&locker.MemoryStore{TTL: ttl},
+				&locker.MemoryStore{TTL: ttl},
+			},
+			TTL: ttl,
+		}
+	})
+}
+
+func TestShardedStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		return &locker.ShardedStore{
+			Shards: []locker.Shard{
+				{Name: "a", Store: &locker.MemoryStore{TTL: ttl}},
+				{Name: "b", Store: &locker.MemoryStore{TTL: ttl}},
+			},
+		}
+	})
+}
+
+func TestChaosStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		return &locker.ChaosStore{Store: &locker.MemoryStore{TTL: ttl}, Seed: 1, Latency: time.Millisecond}
+	})
+}
+
+func TestRemoteStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		srv := server.New(&locker.MemoryStore{TTL: ttl})
+		ts := httptest.NewServer(srv)
+		t.Cleanup(func() {
+			ts.Close()
+			srv.Close()
+		})
+		return &locker.RemoteStore{URL: ts.URL, TTL: ttl}
+	})
+}
+
+func TestEtcdStoreConformance(t *testing.T) {
+	machines := etcdtest.Endpoints(t)
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		client, err := locker.New(machines, 5, ttl, context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.Store
+	})
+}
+
+func TestEtcdV2StoreConformance(t *testing.T) {
+	machines := etcdtest.Endpoints(t)
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		return locker.NewV2(machines, 5, ttl, context.Background()).Store
+	})
+}
@@ -21,3 +21,73 @@ type LockDenied struct {
 func (e LockDenied) Error() string {
 	return fmt.Sprintf("Lock attempt was denied: %s", e.key)
 }
+
+// NewLockDenied returns the LockDenied error of the named lock, for
+// Stores and servers outside this package.
+func NewLockDenied(name string) LockDenied {
+	return LockDenied{name}
+}
+
+// QuorumNotReached is returned by a QuorumStore when not enough of its
+// member stores agreed on the outcome of an operation.
+type QuorumNotReached struct {
+	key  string
+	errs []error
+}
+
+func (e QuorumNotReached) Error() string {
+	return fmt.Sprintf("Quorum not reached: %s %v", e.key, e.errs)
+}
+
+// Unsupported is returned when an operation is made which the Store of a
+// Client doesn't implement.
+type Unsupported struct {
+	operation string
+}
+
+func (e Unsupported) Error() string {
+	return fmt.Sprintf("Operation not supported by the store: %s", e.operation)
+}
+
+// ReasonRequired is returned when an administrative operation is made
+// without a reason to record it with.
+type ReasonRequired struct {
+	operation string
+}
+
+func (e ReasonRequired) Error() string {
+	return fmt.Sprintf("A reason is required for: %s", e.operation)
+}
+
+// ChaosFault is returned by a ChaosStore for the faults it injects.
+type ChaosFault struct {
+	operation string
+	key       string
+	kind      string
+}
+
+func (e ChaosFault) Error() string {
+	return fmt.Sprintf("Chaos %s injected in %s: %s", e.kind, e.operation, e.key)
+}
+
+// LockLost is the cause of the cancellation of the context of a Handle
+// whose lock was lost, to somebody who took it or to expiry.
+type LockLost struct {
+	key    string
+	reason string
+}
+
+func (e LockLost) Error() string {
+	return fmt.Sprintf("Lock was lost (%s): %s", e.reason, e.key)
+}
+
+// StoreUnavailable is returned, without asking the Store, when the
+// Breaker of a Client is open, or its Bulkhead is full.
+type StoreUnavailable struct {
+	operation string
+	key       string
+}
+
+func (e StoreUnavailable) Error() string {
+	return fmt.Sprintf("Store unavailable for %s: %s", e.operation, e.key)
+}
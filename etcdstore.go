@@ -2,9 +2,10 @@ package locker
 
 import (
 	"context"
+	"time"
 
 	"github.com/coreos/etcd/clientv3"
-	"github.com/coreos/go-log/log"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
 )
 
 // EtcdStore is a backing store for Locker which uses Etcd for storage.
@@ -17,44 +18,174 @@ type EtcdStore struct {
 	// TTL is the time-to-live for the lock. Default: 5s.
 	TTL int64
 
-	Log log.Logger
+	// Log, if set, receives a debug event for every operation, with its
+	// key, lease and latency. NewGoLog adapts a go-log Logger.
+	Log Logger
 }
 
 // Get returns the value of a lock. LockNotFound will be returned if a
 // lock with the name isn't held.
 func (s EtcdStore) Get(ctx context.Context, name string) (string, error) {
-	l("Get", "entry")
-	resp, err := s.EtcdClientv3.Get(context.Background(), name)
-	l("Get", resp)
+	start := time.Now()
+	resp, err := s.EtcdClientv3.Get(ctx, name)
+	if err != nil {
+		s.log("Get", name, 0, start, err)
+		return "", err
+	}
 	if resp.Count > 0 {
-		l("Get", string(resp.Kvs[0].Value))
-		return string(resp.Kvs[0].Value), err
+		s.log("Get", name, resp.Kvs[0].Lease, start, nil)
+		return string(resp.Kvs[0].Value), nil
 	}
-	return "", nil
+	s.log("Get", name, 0, start, nil)
+	return "", LockNotFound{name}
 }
 
 // AcquireOrFreshenLock will aquires a named lock if it isn't already
-// held, or updates its TTL if it is.
+// held, or updates its TTL if it is. A lock held with value has the
+// lease of its key kept alive. Otherwise a lease is granted, and the key
+// only created with it if it doesn't exist yet, so a lock held by
+// somebody else is never overwritten.
 func (s EtcdStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
-	l("AcquireOrFreshenLock", "entry")
-	lresp, err := s.EtcdClientv3.Grant(context.Background(), s.lockTTL())
+	start := time.Now()
+	resp, err := s.EtcdClientv3.Get(ctx, name)
+	if err != nil {
+		s.log("AcquireOrFreshenLock", name, 0, start, err)
+		return err
+	}
+	if resp.Count > 0 {
+		kv := resp.Kvs[0]
+		if string(kv.Value) != value {
+			s.log("AcquireOrFreshenLock", name, 0, start, LockDenied{name})
+			return LockDenied{name}
+		}
+		_, err = s.EtcdClientv3.KeepAliveOnce(ctx, clientv3.LeaseID(kv.Lease))
+		if err != rpctypes.ErrLeaseNotFound {
+			s.log("AcquireOrFreshenLock", name, kv.Lease, start, err)
+			return err
+		}
+		// the lease ran out since the Get, and took the key with it
+	}
+
+	lresp, err := s.EtcdClientv3.Grant(ctx, s.lockTTL())
+	if err != nil {
+		s.log("AcquireOrFreshenLock", name, 0, start, err)
+		return err
+	}
+
+	tresp, err := s.EtcdClientv3.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(name), "=", 0)).
+		Then(clientv3.OpPut(name, value, clientv3.WithLease(lresp.ID))).
+		Else(clientv3.OpGet(name)).
+		Commit()
 	if err != nil {
+		s.log("AcquireOrFreshenLock", name, int64(lresp.ID), start, err)
+		s.revoke(ctx, name, lresp.ID)
 		return err
 	}
+	if tresp.Succeeded {
+		s.log("AcquireOrFreshenLock", name, int64(lresp.ID), start, nil)
+		return nil
+	}
+
+	// somebody created the key since the Get, the lease isn't needed
+	s.revoke(ctx, name, lresp.ID)
 
-	l("AcquireOrFreshenLock", lresp.ID)
-	_, err = s.EtcdClientv3.Put(context.Background(), name, value, clientv3.WithLease(lresp.ID))
+	kvs := tresp.Responses[0].GetResponseRange().Kvs
+	if len(kvs) == 0 || string(kvs[0].Value) != value {
+		s.log("AcquireOrFreshenLock", name, 0, start, LockDenied{name})
+		return LockDenied{name}
+	}
 
-	l("AcquireOrFreshenLock", "put happened")
+	_, err = s.EtcdClientv3.KeepAliveOnce(ctx, clientv3.LeaseID(kvs[0].Lease))
+	s.log("AcquireOrFreshenLock", name, kvs[0].Lease, start, err)
 	return err
 }
 
+// Delete releases the named lock, regardless of who holds it.
 func (s EtcdStore) Delete(ctx context.Context, name string) error {
-	_, err := s.EtcdClientv3.Delete(context.Background(), name)
-	l("Delete", "delete happened")
+	start := time.Now()
+	_, err := s.EtcdClientv3.Delete(ctx, name)
+	s.log("Delete", name, 0, start, err)
+	return err
+}
+
+// CompareAndDelete releases the named lock if it's held with value, in a
+// transaction comparing the value.
+func (s EtcdStore) CompareAndDelete(ctx context.Context, name, value string) error {
+	start := time.Now()
+	tresp, err := s.EtcdClientv3.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(name), "=", value)).
+		Then(clientv3.OpDelete(name)).
+		Else(clientv3.OpGet(name)).
+		Commit()
+	if err != nil {
+		s.log("CompareAndDelete", name, 0, start, err)
+		return err
+	}
+	if tresp.Succeeded {
+		s.log("CompareAndDelete", name, 0, start, nil)
+		return nil
+	}
+
+	err = LockDenied{name}
+	if len(tresp.Responses[0].GetResponseRange().Kvs) == 0 {
+		err = LockNotFound{name}
+	}
+	s.log("CompareAndDelete", name, 0, start, err)
 	return err
 }
 
+// Transfer gives the named lock held by from to to, with a fresh TTL.
+// The value is swapped in a transaction comparing it against from, on a
+// new lease, and the lease of from is revoked.
+func (s EtcdStore) Transfer(ctx context.Context, name, from, to string) error {
+	start := time.Now()
+	lresp, err := s.EtcdClientv3.Grant(ctx, s.lockTTL())
+	if err != nil {
+		s.log("Transfer", name, 0, start, err)
+		return err
+	}
+
+	tresp, err := s.EtcdClientv3.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(name), "=", from)).
+		Then(clientv3.OpPut(name, to, clientv3.WithLease(lresp.ID), clientv3.WithPrevKV())).
+		Else(clientv3.OpGet(name)).
+		Commit()
+	if err != nil {
+		s.log("Transfer", name, int64(lresp.ID), start, err)
+		s.revoke(ctx, name, lresp.ID)
+		return err
+	}
+	if tresp.Succeeded {
+		s.log("Transfer", name, int64(lresp.ID), start, nil)
+		if prev := tresp.Responses[0].GetResponsePut().PrevKv; prev != nil && prev.Lease != 0 {
+			s.revoke(ctx, name, clientv3.LeaseID(prev.Lease))
+		}
+		return nil
+	}
+
+	s.revoke(ctx, name, lresp.ID)
+	if len(tresp.Responses[0].GetResponseRange().Kvs) == 0 {
+		return LockNotFound{name}
+	}
+	return LockDenied{name}
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values.
+func (s EtcdStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	resp, err := s.EtcdClientv3.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	locks := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		locks[string(kv.Key)] = string(kv.Value)
+	}
+	return locks, nil
+}
+
 // lockTTL gets the TTL of the locks being stored in Etcd. Defaults to
 // 5 seconds.
 func (s EtcdStore) lockTTL() int64 {
@@ -64,3 +195,24 @@ func (s EtcdStore) lockTTL() int64 {
 
 	return s.TTL
 }
+
+// revoke revokes a lease no key of name is on any more. It would run out
+// after the TTL anyway, so a failure is only logged.
+func (s EtcdStore) revoke(ctx context.Context, name string, lease clientv3.LeaseID) {
+	start := time.Now()
+	_, err := s.EtcdClientv3.Revoke(ctx, lease)
+	s.log("Revoke", name, int64(lease), start, err)
+	if err != nil && s.Log != nil {
+		s.Log.Log(LevelWarn, "etcd lease not revoked", Field{FieldKey, name}, Field{FieldLease, int64(lease)}, Field{FieldError, err})
+	}
+}
+
+// log sends the debug event of an operation to the Logger of the store.
+// A zero lease is left out.
+func (s EtcdStore) log(op, name string, lease int64, start time.Time, err error) {
+	var fields []Field
+	if lease != 0 {
+		fields = append(fields, Field{FieldLease, lease})
+	}
+	logStore(s.Log, "etcd", op, name, start, err, fields...)
+}
@@ -0,0 +1,135 @@
+package locker
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker/etcdtest"
+	"github.com/coreos/etcd/clientv3"
+)
+
+// blackhole accepts connections and never answers on them, like an etcd
+// member which hangs.
+func blackhole(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var conns []net.Conn
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				break
+			}
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		<-done
+	})
+	return l.Addr().String()
+}
+
+func TestEtcdStoreContext(t *testing.T) {
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{blackhole(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	store := EtcdStore{EtcdClientv3: cli}
+
+	calls := map[string]func(ctx context.Context) error{
+		"Get": func(ctx context.Context) error {
+			_, err := store.Get(ctx, name)
+			return err
+		},
+		"AcquireOrFreshenLock": func(ctx context.Context) error {
+			return store.AcquireOrFreshenLock(ctx, name, "a")
+		},
+		"Delete": func(ctx context.Context) error {
+			return store.Delete(ctx, name)
+		},
+		"Transfer": func(ctx context.Context) error {
+			return store.Transfer(ctx, name, "a", "b")
+		},
+		"List": func(ctx context.Context) error {
+			_, err := store.List(ctx, name)
+			return err
+		},
+	}
+	for op, call := range calls {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- call(ctx) }()
+
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("%s: expected an error once cancelled", op)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: still waiting on etcd once cancelled", op)
+		}
+	}
+}
+
+func TestEtcdStoreLeases(t *testing.T) {
+	cli, err := clientv3.New(clientv3.Config{Endpoints: etcdtest.Endpoints(t), DialTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	store := EtcdStore{EtcdClientv3: cli, TTL: 5}
+	ctx := context.Background()
+	key := randomKey()
+
+	lease := func() clientv3.LeaseID {
+		t.Helper()
+		resp, err := cli.Get(ctx, key)
+		if err != nil || resp.Count == 0 {
+			t.Fatalf("get: %v, %d", err, resp.Count)
+		}
+		return clientv3.LeaseID(resp.Kvs[0].Lease)
+	}
+
+	if err := store.AcquireOrFreshenLock(ctx, key, "a"); err != nil {
+		t.Fatal(err)
+	}
+	acquired := lease()
+	for i := 0; i < 3; i++ {
+		if err := store.AcquireOrFreshenLock(ctx, key, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lease() != acquired {
+		t.Error("expected freshening to keep the lease alive rather than grant another")
+	}
+
+	if err := store.Transfer(ctx, key, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if lease() == acquired {
+		t.Error("expected a fresh lease for the new holder")
+	}
+	resp, err := cli.TimeToLive(ctx, acquired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TTL > 0 {
+		t.Errorf("expected the lease of the old holder to be revoked, it has %ds left", resp.TTL)
+	}
+	store.Delete(ctx, key)
+}
@@ -0,0 +1,211 @@
+// Package etcdtest runs etcd for tests, so tests of etcd backed Stores
+// don't depend on a cluster somebody started by hand.
+//
+// The etcd servers run in the test process, from the etcd server
+// packages vendored with locker, listening on ephemeral ports with their
+// data in a temporary directory. Nothing has to be installed for them.
+//
+// Start runs a cluster for a single test:
+//
+//	cluster := etcdtest.Start(t, 3)
+//	client, err := locker.New(cluster.Endpoints, 5, 5, context.Background())
+//
+// Endpoints shares a single node between the tests of a package, started
+// on first use. Packages using it stop it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(etcdtest.Run(m))
+//	}
+//
+// Setting ETCD_ENDPOINTS, to a comma separated list of client URLs, uses
+// a running cluster instead, such as the one of docker/docker-compose.yml.
+package etcdtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/embed"
+	"github.com/coreos/pkg/capnslog"
+)
+
+// startTimeout is how long a cluster has to become ready.
+const startTimeout = 20 * time.Second
+
+func init() {
+	// the servers log every step of their raft to stderr otherwise, and
+	// the peers they lose on the way down
+	capnslog.SetGlobalLogLevel(capnslog.CRITICAL)
+}
+
+// Cluster is a set of etcd servers.
+type Cluster struct {
+	// Endpoints are the client URLs of the members.
+	Endpoints []string
+
+	dir     string
+	members []*embed.Etcd
+}
+
+// Start starts a cluster of members etcd servers, which is stopped when
+// the test ends.
+func Start(t testing.TB, members int) *Cluster {
+	t.Helper()
+
+	c, err := start(members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	return c
+}
+
+// Stop stops the servers of the cluster and removes their data. They're
+// stopped without handing the leadership over first, which would wait on
+// the members stopping alongside.
+func (c *Cluster) Stop() {
+	for _, m := range c.members {
+		m.Server.HardStop()
+		m.Close()
+	}
+	c.members = nil
+	os.RemoveAll(c.dir)
+}
+
+var shared struct {
+	sync.Mutex
+	started   bool
+	cluster   *Cluster
+	endpoints []string
+}
+
+// Endpoints returns the client URLs of ETCD_ENDPOINTS if it's set, and
+// otherwise of a single node started on first use and shared by the
+// tests of the package.
+func Endpoints(t testing.TB) []string {
+	t.Helper()
+
+	shared.Lock()
+	defer shared.Unlock()
+
+	if !shared.started {
+		shared.started = true
+		if env := os.Getenv("ETCD_ENDPOINTS"); env != "" {
+			shared.endpoints = strings.Split(env, ",")
+		} else if c, err := start(1); err != nil {
+			t.Fatal(err)
+		} else {
+			shared.cluster, shared.endpoints = c, c.Endpoints
+		}
+	}
+
+	if shared.endpoints == nil {
+		t.Fatal("etcdtest: the shared node failed to start")
+	}
+	return shared.endpoints
+}
+
+// Run runs the tests of m, and then stops the node shared by Endpoints
+// if it was started. It returns the exit code for os.Exit.
+func Run(m *testing.M) int {
+	code := m.Run()
+
+	shared.Lock()
+	if shared.cluster != nil {
+		shared.cluster.Stop()
+	}
+	shared.Unlock()
+	return code
+}
+
+func start(members int) (*Cluster, error) {
+	dir, err := ioutil.TempDir("", "etcdtest")
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{dir: dir}
+
+	ports, err := freePorts(2 * members)
+	if err != nil {
+		c.Stop()
+		return nil, err
+	}
+
+	var initial []string
+	for i := 0; i < members; i++ {
+		initial = append(initial, fmt.Sprintf("m%d=http://127.0.0.1:%d", i, ports[2*i+1]))
+	}
+
+	// the members only become ready once they've met, so they're all
+	// started before waiting for any
+	for i := 0; i < members; i++ {
+		client := url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", ports[2*i])}
+		peer := url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", ports[2*i+1])}
+
+		cfg := embed.NewConfig()
+		cfg.Name = fmt.Sprintf("m%d", i)
+		cfg.Dir = filepath.Join(dir, cfg.Name)
+		cfg.LCUrls, cfg.ACUrls = []url.URL{client}, []url.URL{client}
+		cfg.LPUrls, cfg.APUrls = []url.URL{peer}, []url.URL{peer}
+		cfg.InitialCluster = strings.Join(initial, ",")
+		cfg.ClusterState = embed.ClusterStateFlagNew
+		cfg.InitialClusterToken = filepath.Base(dir)
+		cfg.EnableV2 = true
+		// leases can't be shorter than 1.5 election timeouts, keep that
+		// within the second tests give locks to expire
+		cfg.TickMs = 50
+		cfg.ElectionMs = 500
+
+		m, err := embed.StartEtcd(cfg)
+		if err != nil {
+			c.Stop()
+			return nil, err
+		}
+		c.members = append(c.members, m)
+		c.Endpoints = append(c.Endpoints, client.String())
+	}
+
+	if err := c.waitReady(); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// waitReady waits until every member is ready to serve its clients.
+func (c *Cluster) waitReady() error {
+	timeout := time.After(startTimeout)
+	for i, m := range c.members {
+		select {
+		case <-m.Server.ReadyNotify():
+		case err := <-m.Err():
+			return fmt.Errorf("etcdtest: %s failed: %v", c.Endpoints[i], err)
+		case <-timeout:
+			return fmt.Errorf("etcdtest: %s isn't ready after %s", c.Endpoints[i], startTimeout)
+		}
+	}
+	return nil
+}
+
+// freePorts finds n free TCP ports. They're free when they're found, not
+// reserved; another process could take one before etcd does.
+func freePorts(n int) ([]int, error) {
+	var ports []int
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer l.Close()
+		ports = append(ports, l.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
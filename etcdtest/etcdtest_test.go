@@ -0,0 +1,32 @@
+package etcdtest
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFreePorts(t *testing.T) {
+	ports, err := freePorts(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]bool)
+	for _, port := range ports {
+		if port == 0 || seen[port] {
+			t.Errorf("ports: %v", ports)
+		}
+		seen[port] = true
+	}
+}
+
+func TestStart(t *testing.T) {
+	c := Start(t, 3)
+	if len(c.Endpoints) != 3 {
+		t.Fatalf("endpoints: %v", c.Endpoints)
+	}
+
+	c.Stop()
+	if _, err := http.Get(c.Endpoints[0] + "/health"); err == nil {
+		t.Error("expected the cluster to be stopped")
+	}
+}
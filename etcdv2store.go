@@ -0,0 +1,269 @@
+package locker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+// etcd v2 error codes the store cares about.
+const (
+	etcdKeyNotFound     = 100
+	etcdTestFailed      = 101
+	etcdNodeExist       = 105
+	etcdEventIndexClear = 401
+)
+
+// EtcdV2Store is a backing store for Locker which uses the etcd v2 API
+// for storage. Use it with clusters which don't serve the v3 API yet;
+// otherwise EtcdStore is preferred.
+type EtcdV2Store struct {
+	EtcdClient *etcd.Client
+
+	// TTL is the time-to-live for the lock in seconds. Default: 5s.
+	TTL int64
+
+	// Log, if set, receives a debug event for every operation, with its
+	// key and latency.
+	Log Logger
+}
+
+// NewV2 creates a locker client using an etcd v2 cluster as a store.
+//
+//     client := locker.NewV2(machines, 5, 5, context.Background())
+//
+func NewV2(machines []string, timeout int64, ttl int64, ctx context.Context) Client {
+	cli := etcd.NewClient(machines)
+	cli.SetDialTimeout(time.Duration(timeout) * time.Second)
+
+	return Client{
+		Store: EtcdV2Store{
+			EtcdClient: cli,
+			TTL:        ttl,
+		},
+		ctx: ctx,
+	}
+}
+
+// Get returns the value of a lock. LockNotFound will be returned if a
+// lock with the name isn't held.
+func (s EtcdV2Store) Get(ctx context.Context, name string) (_ string, err error) {
+	defer s.log("Get", name, time.Now(), &err)
+
+	resp, err := s.EtcdClient.Get(name, false, false)
+	if err != nil {
+		if etcdErrorCode(err) == etcdKeyNotFound {
+			return "", LockNotFound{name}
+		}
+		return "", err
+	}
+
+	return resp.Node.Value, nil
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is. The lock is created with
+// prevExist=false, and freshened with a compare-and-swap against our own
+// value so a lock held by somebody else is never overwritten.
+func (s EtcdV2Store) AcquireOrFreshenLock(ctx context.Context, name, value string) (err error) {
+	defer s.log("AcquireOrFreshenLock", name, time.Now(), &err)
+
+	ttl := uint64(s.lockTTL())
+
+	for {
+		_, err := s.EtcdClient.Create(name, value, ttl)
+		if etcdErrorCode(err) != etcdNodeExist {
+			return err
+		}
+
+		_, err = s.EtcdClient.CompareAndSwap(name, value, ttl, value, 0)
+		switch etcdErrorCode(err) {
+		case etcdTestFailed:
+			return LockDenied{name}
+		case etcdKeyNotFound:
+			// expired between the create and the swap, try again
+			continue
+		}
+		return err
+	}
+}
+
+// Delete releases the named lock, regardless of who holds it.
+func (s EtcdV2Store) Delete(ctx context.Context, name string) (err error) {
+	defer s.log("Delete", name, time.Now(), &err)
+
+	_, err = s.EtcdClient.Delete(name, false)
+	if etcdErrorCode(err) == etcdKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// CompareAndDelete releases the named lock if it's held with value, with
+// a compare-and-delete against it.
+func (s EtcdV2Store) CompareAndDelete(ctx context.Context, name, value string) (err error) {
+	defer s.log("CompareAndDelete", name, time.Now(), &err)
+
+	_, err = s.EtcdClient.CompareAndDelete(name, value, 0)
+	switch etcdErrorCode(err) {
+	case etcdTestFailed:
+		return LockDenied{name}
+	case etcdKeyNotFound:
+		return LockNotFound{name}
+	}
+	return err
+}
+
+// Transfer gives the named lock held by from to to, with a fresh TTL,
+// with a compare-and-swap against from.
+func (s EtcdV2Store) Transfer(ctx context.Context, name, from, to string) (err error) {
+	defer s.log("Transfer", name, time.Now(), &err)
+
+	_, err = s.EtcdClient.CompareAndSwap(name, to, uint64(s.lockTTL()), from, 0)
+	switch etcdErrorCode(err) {
+	case etcdTestFailed:
+		return LockDenied{name}
+	case etcdKeyNotFound:
+		return LockNotFound{name}
+	}
+	return err
+}
+
+// Watch pushes the value of the named lock into valueChanges, and then
+// every change to it, until ctx is done. An empty string indicates the
+// lack of a lock. Rather than polling, Watch long-polls etcd from the
+// index of the last change it saw, so no change is missed in between.
+func (s EtcdV2Store) Watch(ctx context.Context, name string, valueChanges chan<- string) error {
+	stop := make(chan bool)
+	go func() {
+		<-ctx.Done()
+		close(stop)
+	}()
+
+	var lastValue string
+	first := true
+	push := func(v string) bool {
+		if v == lastValue && !first {
+			return true
+		}
+		first = false
+		lastValue = v
+		select {
+		case valueChanges <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		value, index, err := s.current(name)
+		if err != nil {
+			return err
+		}
+		if !push(value) {
+			return nil
+		}
+
+		for {
+			resp, err := s.EtcdClient.Watch(name, index+1, false, nil, stop)
+			if err == etcd.ErrWatchStoppedByUser {
+				return nil
+			}
+			if etcdErrorCode(err) == etcdEventIndexClear {
+				// we fell too far behind, start again from the current value
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			index = resp.Node.ModifiedIndex
+			switch resp.Action {
+			case "delete", "expire", "compareAndDelete":
+				value = ""
+			default:
+				value = resp.Node.Value
+			}
+			if !push(value) {
+				return nil
+			}
+		}
+	}
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values. The v2 keyspace is a tree, so the directory the prefix
+// ends in is listed recursively.
+func (s EtcdV2Store) List(ctx context.Context, prefix string) (map[string]string, error) {
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+	if dir == "" {
+		dir = "/"
+	}
+
+	locks := make(map[string]string)
+	resp, err := s.EtcdClient.Get(dir, false, true)
+	if err != nil {
+		if etcdErrorCode(err) == etcdKeyNotFound {
+			return locks, nil
+		}
+		return nil, err
+	}
+
+	var walk func(node *etcd.Node)
+	walk = func(node *etcd.Node) {
+		if node.Dir {
+			for _, child := range node.Nodes {
+				walk(child)
+			}
+			return
+		}
+		name := strings.TrimPrefix(node.Key, "/")
+		if strings.HasPrefix(name, strings.TrimPrefix(prefix, "/")) {
+			locks[name] = node.Value
+		}
+	}
+	walk(resp.Node)
+
+	return locks, nil
+}
+
+// current gets the value of a lock and the etcd index it's valid at.
+func (s EtcdV2Store) current(name string) (string, uint64, error) {
+	resp, err := s.EtcdClient.Get(name, false, false)
+	if err != nil {
+		if e, ok := err.(*etcd.EtcdError); ok && e.ErrorCode == etcdKeyNotFound {
+			return "", e.Index, nil
+		}
+		return "", 0, err
+	}
+
+	return resp.Node.Value, resp.EtcdIndex, nil
+}
+
+// lockTTL gets the TTL of the locks being stored in Etcd. Defaults to
+// 5 seconds.
+func (s EtcdV2Store) lockTTL() int64 {
+	if s.TTL <= 0 {
+		return 5
+	}
+
+	return s.TTL
+}
+
+// etcdErrorCode returns the etcd error code of err, or 0 if err isn't an
+// etcd error.
+func etcdErrorCode(err error) int {
+	if e, ok := err.(*etcd.EtcdError); ok {
+		return e.ErrorCode
+	}
+	return 0
+}
+
+// log sends the debug event of an operation, deferred by it, to the
+// Logger of the store.
+func (s EtcdV2Store) log(op, name string, start time.Time, err *error) {
+	logStore(s.Log, "etcd v2", op, name, start, *err)
+}
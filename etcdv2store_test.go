@@ -0,0 +1,34 @@
+package locker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PumpkinSeed/locker/etcdtest"
+)
+
+func TestEtcdV2LockAndUnlock(t *testing.T) {
+	client := NewV2(etcdtest.Endpoints(t), 5, 5, context.Background())
+
+	key := randomKey()
+
+	quit := make(chan bool)
+	report := client.Lock(key, DefaultValue, quit)
+	if report.Err != nil {
+		t.Error(report.Err)
+	}
+	if report.Msg != Success {
+		t.Errorf("Report message should be '%s', instead of %s", Success, report.Msg)
+	}
+
+	err := client.Store.AcquireOrFreshenLock(context.Background(), key, "other")
+	if _, ok := err.(LockDenied); !ok {
+		t.Errorf("Expected LockDenied, got %v", err)
+	}
+
+	client.Unlock(key, quit)
+
+	if _, err := client.Get(key); err != (LockNotFound{key}) {
+		t.Errorf("Expected LockNotFound, got %v", err)
+	}
+}
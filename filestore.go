@@ -0,0 +1,306 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package locker
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FileStore is a backing store for Locker which keeps its locks on the
+// local filesystem. It's intended for developer machines and single-host
+// deployments where running etcd isn't worth the trouble.
+//
+// Every lock is a file in Dir holding the expiry time and the value of
+// the lock. Access to a lock file is serialised with flock(2), so
+// multiple processes on the same host can share a Dir safely.
+type FileStore struct {
+	// Dir is the directory the lock files are kept in. It's created on
+	// first use if it doesn't exist.
+	Dir string
+
+	// TTL is the time-to-live for the lock in seconds. Default: 5s.
+	TTL int64
+
+	// Log, if set, receives a debug event for every operation, with its
+	// key and latency.
+	Log Logger
+}
+
+// Get returns the value of a lock. LockNotFound will be returned if a
+// lock with the name isn't held.
+func (s FileStore) Get(ctx context.Context, name string) (_ string, err error) {
+	defer s.log("Get", name, time.Now(), &err)
+
+	var value string
+	err = s.withFile(name, false, func(f *os.File) error {
+		v, ok, err := readLockFile(f)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return LockNotFound{name}
+		}
+		value = v
+		return nil
+	})
+	return value, err
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is.
+func (s FileStore) AcquireOrFreshenLock(ctx context.Context, name, value string) (err error) {
+	defer s.log("AcquireOrFreshenLock", name, time.Now(), &err)
+
+	return s.withFile(name, true, func(f *os.File) error {
+		v, ok, err := readLockFile(f)
+		if err != nil {
+			return err
+		}
+		if ok && v != value {
+			return LockDenied{name}
+		}
+
+		expires := time.Now().Add(time.Duration(s.lockTTL()) * time.Second)
+		return writeLockFile(f, expires, value)
+	})
+}
+
+// Delete releases the named lock, regardless of who holds it.
+func (s FileStore) Delete(ctx context.Context, name string) (err error) {
+	defer s.log("Delete", name, time.Now(), &err)
+
+	err = s.withFile(name, false, func(f *os.File) error {
+		return f.Truncate(0)
+	})
+	if _, ok := err.(LockNotFound); ok {
+		return nil
+	}
+	return err
+}
+
+// CompareAndDelete releases the named lock if it's held with value.
+func (s FileStore) CompareAndDelete(ctx context.Context, name, value string) (err error) {
+	defer s.log("CompareAndDelete", name, time.Now(), &err)
+
+	return s.withFile(name, false, func(f *os.File) error {
+		v, ok, err := readLockFile(f)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return LockNotFound{name}
+		}
+		if v != value {
+			return LockDenied{name}
+		}
+		return f.Truncate(0)
+	})
+}
+
+// Transfer gives the named lock held by from to to, with a fresh TTL.
+func (s FileStore) Transfer(ctx context.Context, name, from, to string) (err error) {
+	defer s.log("Transfer", name, time.Now(), &err)
+
+	return s.withFile(name, false, func(f *os.File) error {
+		v, ok, err := readLockFile(f)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return LockNotFound{name}
+		}
+		if v != from {
+			return LockDenied{name}
+		}
+
+		expires := time.Now().Add(time.Duration(s.lockTTL()) * time.Second)
+		return writeLockFile(f, expires, to)
+	})
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values.
+func (s FileStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	locks := make(map[string]string)
+
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return locks, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".lock") {
+			continue
+		}
+		name, err := url.PathUnescape(strings.TrimSuffix(file.Name(), ".lock"))
+		if err != nil || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		v, err := s.Get(ctx, name)
+		switch err.(type) {
+		case nil:
+			locks[name] = v
+		case LockNotFound:
+		default:
+			return nil, err
+		}
+	}
+	return locks, nil
+}
+
+// withFile opens the lock file for name and holds an exclusive flock on
+// it for the duration of fn. The file is only created if create is set;
+// otherwise a missing one is LockNotFound. A file which doesn't hold a
+// lock once fn is done is removed, and a flock which was taken on a file
+// removed meanwhile is taken again on the one now at its path.
+func (s FileStore) withFile(name string, create bool, fn func(f *os.File) error) error {
+	flags := os.O_RDWR
+	if create {
+		if err := os.MkdirAll(s.Dir, 0755); err != nil {
+			return err
+		}
+		flags |= os.O_CREATE
+	}
+
+	for {
+		f, err := os.OpenFile(s.path(name), flags, 0644)
+		if os.IsNotExist(err) {
+			return LockNotFound{name}
+		}
+		if err != nil {
+			return err
+		}
+
+		current, err := s.lockFile(f, name)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if !current {
+			f.Close()
+			continue
+		}
+
+		err = fn(f)
+		if _, ok, rerr := readLockFile(f); rerr == nil && !ok {
+			os.Remove(s.path(name))
+		}
+		flock(f, syscall.LOCK_UN)
+		f.Close()
+		return err
+	}
+}
+
+// lockFile takes the flock of the lock file f, and reports whether f is
+// still the file at the path of name. It's left unlocked if it isn't.
+func (s FileStore) lockFile(f *os.File, name string) (bool, error) {
+	if err := flock(f, syscall.LOCK_EX); err != nil {
+		return false, err
+	}
+
+	opened, err := f.Stat()
+	if err != nil {
+		flock(f, syscall.LOCK_UN)
+		return false, err
+	}
+	current, err := os.Stat(s.path(name))
+	switch {
+	case os.IsNotExist(err):
+		flock(f, syscall.LOCK_UN)
+		return false, nil
+	case err != nil:
+		flock(f, syscall.LOCK_UN)
+		return false, err
+	case !os.SameFile(opened, current):
+		flock(f, syscall.LOCK_UN)
+		return false, nil
+	}
+	return true, nil
+}
+
+// path maps a lock name onto a file in Dir. Names are escaped so a lock
+// can't point outside of Dir.
+func (s FileStore) path(name string) string {
+	return filepath.Join(s.Dir, url.PathEscape(name)+".lock")
+}
+
+// lockTTL gets the TTL of the locks being stored in files. Defaults to
+// 5 seconds.
+func (s FileStore) lockTTL() int64 {
+	if s.TTL <= 0 {
+		return 5
+	}
+
+	return s.TTL
+}
+
+// readLockFile reads the value of a lock file. ok is false when the file
+// is empty or the lock in it has expired.
+func readLockFile(f *os.File) (value string, ok bool, err error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", false, err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", false, err
+	}
+
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return "", false, nil
+	}
+	expires, err := strconv.ParseInt(string(data[:i]), 10, 64)
+	if err != nil {
+		// a torn or foreign file, treat it as unlocked
+		return "", false, nil
+	}
+	if time.Now().UnixNano() >= expires {
+		return "", false, nil
+	}
+
+	return string(data[i+1:]), true, nil
+}
+
+func writeLockFile(f *os.File, expires time.Time, value string) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	data := strconv.AppendInt(nil, expires.UnixNano(), 10)
+	data = append(data, '\n')
+	data = append(data, value...)
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// flock retries on EINTR, which a blocking flock returns whenever the
+// process receives a signal while waiting.
+func flock(f *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// log sends the debug event of an operation, deferred by it, to the
+// Logger of the store.
+func (s FileStore) log(op, name string, start time.Time, err *error) {
+	logStore(s.Log, "file", op, name, start, *err)
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package locker_test
+
+import (
+	"testing"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/storetest"
+)
+
+func TestFileStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+		return locker.FileStore{Dir: t.TempDir(), TTL: ttl}
+	})
+}
@@ -0,0 +1,134 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package locker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileStoreLockAndUnlock(t *testing.T) {
+	store := FileStore{Dir: t.TempDir()}
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, name); err != (LockNotFound{name}) {
+		t.Fatalf("Expected LockNotFound, got %v", err)
+	}
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Errorf("Expected owner to freshen the lock, got %v", err)
+	}
+
+	// a second FileStore on the same directory sees the same locks
+	other := FileStore{Dir: store.Dir}
+	if err := other.AcquireOrFreshenLock(ctx, name, "b"); err != (LockDenied{name}) {
+		t.Errorf("Expected LockDenied, got %v", err)
+	}
+	if v, err := other.Get(ctx, name); err != nil || v != "a" {
+		t.Errorf("Expected value 'a', got %q, %v", v, err)
+	}
+
+	if err := other.Delete(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.AcquireOrFreshenLock(ctx, name, "b"); err != nil {
+		t.Errorf("Expected lock to be free after Delete, got %v", err)
+	}
+}
+
+func TestFileStoreLogs(t *testing.T) {
+	logs := &logRecorder{}
+	store := FileStore{Dir: t.TempDir(), Log: logs}
+
+	store.Get(context.Background(), name)
+	if ev := logs.find(t, "file Get"); ev.fields[FieldKey] != name || ev.fields[FieldError] != (LockNotFound{name}) {
+		t.Errorf("get: %+v", ev)
+	}
+}
+
+func TestFileStoreTTL(t *testing.T) {
+	store := FileStore{Dir: t.TempDir(), TTL: 1}
+	ctx := context.Background()
+
+	if err := store.AcquireOrFreshenLock(ctx, "a/../b", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if _, err := store.Get(ctx, "a/../b"); err != (LockNotFound{"a/../b"}) {
+		t.Errorf("Expected lock to expire, got %v", err)
+	}
+	if err := store.AcquireOrFreshenLock(ctx, "a/../b", "b"); err != nil {
+		t.Errorf("Expected expired lock to be free, got %v", err)
+	}
+}
+
+func TestFileStoreRemovesLockFiles(t *testing.T) {
+	store := FileStore{Dir: t.TempDir()}
+	ctx := context.Background()
+	files := func() []string {
+		names, _ := filepath.Glob(filepath.Join(store.Dir, "*"))
+		return names
+	}
+
+	store.Get(ctx, name)
+	store.CompareAndDelete(ctx, name, "a")
+	if err := store.Delete(ctx, name); err != nil {
+		t.Errorf("Expected Delete of a missing lock to succeed, got %v", err)
+	}
+	if f := files(); len(f) != 0 {
+		t.Errorf("Expected reads to leave no files, got %v", f)
+	}
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+	if f := files(); len(f) != 0 {
+		t.Errorf("Expected Delete to remove the lock file, got %v", f)
+	}
+	if _, err := os.Stat(store.path(name)); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be gone, got %v", err)
+	}
+}
+
+func TestFileStoreExclusiveWhileRemoving(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	var holders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(value string) {
+			defer wg.Done()
+			store := FileStore{Dir: dir}
+			for j := 0; j < 50; j++ {
+				if store.AcquireOrFreshenLock(ctx, name, value) != nil {
+					continue
+				}
+				if n := atomic.AddInt32(&holders, 1); n > 1 {
+					t.Errorf("%d holders at once", n)
+				}
+				atomic.AddInt32(&holders, -1)
+				if err := store.CompareAndDelete(ctx, name, value); err != nil {
+					t.Errorf("Expected the holder to release the lock, got %v", err)
+				}
+			}
+		}(fmt.Sprint(i))
+	}
+	wg.Wait()
+}
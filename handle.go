@@ -0,0 +1,290 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Handle is a lock acquired with Acquire. The Client keeps freshening it
+// until it's released, or lost: taken by somebody else, or expired as
+// the Client couldn't freshen it within the TTL. A lost lock may be
+// recovered, depending on the Recovery of the Client.
+//
+//     lock, err := client.Acquire("reports", hostname)
+//     if err != nil {
+//         return err
+//     }
+//     defer lock.Release()
+//
+//     lock.OnLost(func() { log.Print("lost the reports lock") })
+//     return generateReports(lock.Context())
+type Handle struct {
+	// Name and Value are the name and value of the lock.
+	Name  string
+	Value string
+
+	client Client
+	quit   chan bool
+	done   chan struct{}
+
+	mu          sync.Mutex
+	ctx         context.Context
+	cancel      context.CancelCauseFunc
+	freshened   time.Time
+	lost        bool
+	abandoned   bool
+	released    bool
+	onLost      []func()
+	onOwnership []func(OwnershipEvent)
+}
+
+// Acquire acquires the named lock with value, and keeps freshening it
+// until the returned Handle is released. LockDenied is returned if
+// somebody else holds it.
+func (c Client) Acquire(name, value string) (*Handle, error) {
+	c, span := c.trace("Acquire", name)
+
+	start := c.clock().Now()
+	state, err := c.updateNode(name, value)
+	if err != nil {
+		return nil, endSpan(span, err)
+	}
+	if state == released {
+		return nil, endSpan(span, LockDenied{name})
+	}
+	endSpan(span, nil)
+
+	h := &Handle{
+		Name:      name,
+		Value:     value,
+		client:    c,
+		quit:      make(chan bool),
+		done:      make(chan struct{}),
+		freshened: start,
+	}
+	h.ctx, h.cancel = context.WithCancelCause(c.context())
+	go func() {
+		defer close(h.done)
+		c.hold(name, value, start, h.quit, h)
+	}()
+	return h, nil
+}
+
+// Context returns a context which is cancelled once the lock is lost or
+// released, or the Client's context is done. Its cause is a LockLost
+// error if the lock was lost. Once a lost lock is recovered, Context
+// returns a new context.
+func (h *Handle) Context() context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.ctx
+}
+
+// ValidUntil returns until when the lock is held for certain. Its lease
+// is counted from when the request which last freshened it was sent, as
+// the Store may have started it any time until the answer came back, and
+// the Client's Drift share of the TTL is set aside for the clocks of the
+// Client and the Store drifting apart. ValidUntil is zero if the lock
+// isn't held, or if the Client doesn't know the TTL.
+func (h *Handle) ValidUntil() time.Time {
+	ttl := h.client.ttl()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.lost || h.released || ttl <= 0 {
+		return time.Time{}
+	}
+	return h.freshened.Add(ttl - time.Duration(float64(ttl)*h.client.drift()))
+}
+
+// StillValid tells whether the lock is still held for certain. Check it
+// right before an operation which is only safe while holding the lock.
+//
+//     if !lock.StillValid() {
+//         return errors.New("lost the lock")
+//     }
+//     return db.Exec(migration)
+func (h *Handle) StillValid() bool {
+	return h.client.clock().Now().Before(h.ValidUntil())
+}
+
+// OnLost registers f to be called every time the lock is lost, after its
+// context has been cancelled. f is called straight away if the lock is
+// lost already, and never if it's released first. f is called by the
+// goroutine freshening the lock, so it mustn't block nor call Release.
+func (h *Handle) OnLost(f func()) {
+	h.mu.Lock()
+	h.onLost = append(h.onLost, f)
+	lost := h.lost
+	h.mu.Unlock()
+
+	if lost {
+		f()
+	}
+}
+
+// OnOwnership registers f to be called with every change of the
+// ownership of the lock, in order. Like for OnLost, f mustn't block nor
+// call Release.
+func (h *Handle) OnOwnership(f func(OwnershipEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.onOwnership = append(h.onOwnership, f)
+}
+
+// Release stops freshening the lock, or recovering it, and releases it
+// unless it has been lost meanwhile. Releasing it again does nothing, and
+// releasing an abandoned lock only stops its Handle, without reporting
+// OwnershipReleased.
+func (h *Handle) Release() error {
+	h.mu.Lock()
+	if h.released {
+		h.mu.Unlock()
+		return nil
+	}
+	h.released = true
+	h.mu.Unlock()
+
+	close(h.quit)
+	<-h.done
+
+	h.mu.Lock()
+	lost, abandoned, cancel := h.lost, h.abandoned, h.cancel
+	h.mu.Unlock()
+	defer cancel(context.Canceled)
+	if abandoned {
+		// that was final already
+		return nil
+	}
+	defer h.event(OwnershipReleased, nil)
+	if lost {
+		return nil
+	}
+
+	// it may have been lost since it was last freshened, and taken by
+	// somebody else since it was asked for
+	c := h.client
+	err := c.traceStore("CompareAndDelete", h.Name, func(ctx context.Context) error {
+		return compareAndDelete(ctx, c.Store, h.Name, h.Value)
+	})
+	switch err.(type) {
+	case nil:
+	case LockDenied, LockNotFound:
+		return nil
+	default:
+		return c.storeError("CompareAndDelete", h.Name, err)
+	}
+	c.metrics().Release(h.Name)
+	c.logger().Log(LevelInfo, "lock released", Field{FieldOp, "Release"}, Field{FieldKey, h.Name})
+	return nil
+}
+
+// lose cancels the context of the Handle, and reports the loss to its
+// OnLost and OnOwnership functions. h may be nil, for locks held with
+// Lock, and so may the other methods below.
+func (h *Handle) lose(err LockLost) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	h.lost = true
+	h.cancel(err)
+	onLost := h.onLost
+	h.mu.Unlock()
+
+	for _, f := range onLost {
+		f()
+	}
+	h.event(OwnershipLost, err)
+}
+
+// freshen records that the lock was freshened by a request sent at
+// start.
+func (h *Handle) freshen(start time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.freshened = start
+}
+
+// recover gives the Handle a new context for the lock it acquired again
+// with a request sent at start.
+func (h *Handle) recover(start time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	h.freshened = start
+	h.lost = false
+	h.ctx, h.cancel = context.WithCancelCause(h.client.context())
+	h.mu.Unlock()
+
+	h.event(OwnershipRecovered, nil)
+}
+
+// abandon reports that the lock won't be recovered, because of err.
+func (h *Handle) abandon(err error) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	h.abandoned = true
+	h.mu.Unlock()
+
+	h.event(OwnershipAbandoned, err)
+}
+
+func (h *Handle) event(state Ownership, err error) {
+	h.mu.Lock()
+	onOwnership := h.onOwnership
+	h.mu.Unlock()
+
+	e := OwnershipEvent{Name: h.Name, State: state, Err: err}
+	for _, f := range onOwnership {
+		f(e)
+	}
+}
+
+// ttl returns the TTL of the Client's locks: its TTL, or that of its
+// Store if it's one of this package. Zero if it isn't known.
+func (c Client) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	if s, ok := c.Store.(interface{ lockTTL() int64 }); ok {
+		return time.Duration(s.lockTTL()) * time.Second
+	}
+	return 0
+}
+
+// drift returns the share of the TTL the Client sets aside for clock
+// drift.
+func (c Client) drift() float64 {
+	switch {
+	case c.Drift < 0:
+		return 0
+	case c.Drift == 0:
+		return 0.01
+	}
+	return c.Drift
+}
+
+// refreshInterval is how often the Client freshens the locks it holds:
+// every 500ms, or three times per TTL if that's more often.
+func (c Client) refreshInterval() time.Duration {
+	if ttl := c.ttl(); ttl > 0 && ttl/3 < refreshInterval {
+		return ttl / 3
+	}
+	return refreshInterval
+}
@@ -0,0 +1,359 @@
+package locker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker/clocktest"
+)
+
+// tick advances clock by one refresh of client, once it's waiting for it.
+func tick(clock *clocktest.Clock, client Client) {
+	clock.BlockUntil(1)
+	clock.Advance(client.refreshInterval())
+}
+
+func waitDone(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the context to be cancelled")
+	}
+}
+
+func TestLockFreshened(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &MemoryStore{TTL: 2, Clock: clock}
+	client := Client{Store: store, Clock: clock}
+
+	quit := make(chan bool)
+	if report := client.Lock(name, "a", quit); report.Msg != Success {
+		t.Fatalf("%+v", report)
+	}
+	for i := 0; i < 10; i++ {
+		tick(clock, client)
+	}
+	if v, err := client.Get(name); v != "a" || err != nil {
+		t.Fatalf("expected the lock to be freshened, got %q, %v", v, err)
+	}
+
+	// stopped, it expires
+	quit <- true
+	clock.Advance(2 * time.Second)
+	if _, err := client.Get(name); err == nil {
+		t.Error("expected the lock to have expired")
+	}
+}
+
+func TestHandleLostToSomebodyElse(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &MemoryStore{TTL: 5, Clock: clock}
+	client := Client{Store: store, Clock: clock}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost := make(chan bool, 1)
+	lock.OnLost(func() { lost <- true })
+
+	if _, err := client.Acquire(name, "b"); err != (LockDenied{name}) {
+		t.Fatalf("expected LockDenied, got %v", err)
+	}
+
+	store.Delete(context.Background(), name)
+	store.AcquireOrFreshenLock(context.Background(), name, "b")
+	tick(clock, client)
+
+	waitDone(t, lock.Context())
+	if cause := context.Cause(lock.Context()); cause != (LockLost{name, "denied"}) {
+		t.Errorf("unexpected cause: %v", cause)
+	}
+	<-lost
+
+	called := false
+	lock.OnLost(func() { called = true })
+	if !called {
+		t.Error("expected OnLost to call back straight away once lost")
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := client.Get(name); v != "b" {
+		t.Errorf("expected the new holder's lock to be left alone, got %q", v)
+	}
+}
+
+func TestHandleExpired(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &ChaosStore{Store: &MemoryStore{TTL: 2, Clock: clock}, PartitionHang: time.Nanosecond}
+	client := Client{Store: store, Clock: clock, TTL: 2 * time.Second}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the store can't be reached until the lock has expired
+	store.Partition()
+	for i := 0; i < 4; i++ {
+		if lock.Context().Err() != nil {
+			t.Fatalf("lost after %d failed refreshes", i)
+		}
+		tick(clock, client)
+	}
+
+	waitDone(t, lock.Context())
+	if cause := context.Cause(lock.Context()); cause != (LockLost{name, "expired"}) {
+		t.Errorf("unexpected cause: %v", cause)
+	}
+}
+
+func TestHandleRelease(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	client := Client{Store: &MemoryStore{Clock: clock}, Clock: clock}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.OnLost(func() { t.Error("released lock reported lost") })
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if cause := context.Cause(lock.Context()); cause != context.Canceled {
+		t.Errorf("unexpected cause: %v", cause)
+	}
+	if _, err := client.Get(name); err == nil {
+		t.Error("expected the lock to be released")
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("releasing again: %v", err)
+	}
+}
+
+// takenStore has its locks taken by "b" as soon as they're looked at or
+// released, as though their lease ran out right then.
+type takenStore struct {
+	handoverStore
+}
+
+func (s *takenStore) CompareAndDelete(ctx context.Context, name, value string) error {
+	if v, err := s.MemoryStore.Get(ctx, name); err == nil {
+		s.MemoryStore.Transfer(ctx, name, v, "b")
+	}
+	return s.MemoryStore.CompareAndDelete(ctx, name, value)
+}
+
+func TestHandleReleaseChangedHands(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &takenStore{handoverStore{MemoryStore{Clock: clock}}}
+	client := Client{Store: store, Clock: clock}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.MemoryStore.Get(context.Background(), name); v != "b" {
+		t.Errorf("expected the new holder's lock to be left alone, got %q", v)
+	}
+}
+
+func ownership(t *testing.T, events <-chan OwnershipEvent, want ...Ownership) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e.State != w {
+				t.Fatalf("expected %s, got %s (%v)", w, e.State, e.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %s", w)
+		}
+	}
+}
+
+func TestRecoveryAfterOutage(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &ChaosStore{Store: &MemoryStore{TTL: 2, Clock: clock}, PartitionHang: time.Nanosecond}
+	client := Client{Store: store, Clock: clock, TTL: 2 * time.Second, Recovery: ReacquireIfFree}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan OwnershipEvent, 10)
+	lock.OnOwnership(func(e OwnershipEvent) { events <- e })
+	first := lock.Context()
+
+	store.Partition()
+	for i := 0; i < 4; i++ {
+		tick(clock, client)
+	}
+	ownership(t, events, OwnershipLost)
+	if context.Cause(first) != (LockLost{name, "expired"}) {
+		t.Errorf("unexpected cause: %v", context.Cause(first))
+	}
+
+	// still down, then back
+	tick(clock, client)
+	store.Heal()
+	tick(clock, client)
+	ownership(t, events, OwnershipRecovered)
+	if lock.Context().Err() != nil {
+		t.Error("expected a live context once recovered")
+	}
+	if v, _ := client.Get(name); v != "a" {
+		t.Errorf("expected the lock to be held again, got %q", v)
+	}
+
+	lock.Release()
+	ownership(t, events, OwnershipReleased)
+}
+
+func TestRecoveryIfFreeAbandons(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &MemoryStore{TTL: 5, Clock: clock}
+	client := Client{Store: store, Clock: clock, Recovery: ReacquireIfFree}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan OwnershipEvent, 10)
+	lock.OnOwnership(func(e OwnershipEvent) { events <- e })
+
+	store.Delete(context.Background(), name)
+	store.AcquireOrFreshenLock(context.Background(), name, "b")
+	tick(clock, client)
+	ownership(t, events, OwnershipLost, OwnershipAbandoned)
+
+	lock.Release()
+	if v, _ := client.Get(name); v != "b" {
+		t.Errorf("expected the new holder's lock to be left alone, got %q", v)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("expected no event once abandoned, got %s", e.State)
+	default:
+	}
+}
+
+func TestRecoveryWaits(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &MemoryStore{TTL: 5, Clock: clock}
+	client := Client{Store: store, Clock: clock, Recovery: ReacquireAndWait}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan OwnershipEvent, 10)
+	lock.OnOwnership(func(e OwnershipEvent) { events <- e })
+
+	store.Delete(context.Background(), name)
+	store.AcquireOrFreshenLock(context.Background(), name, "b")
+	tick(clock, client)
+	ownership(t, events, OwnershipLost)
+
+	// b holds it a while, then lets it go
+	tick(clock, client)
+	tick(clock, client)
+	if v, _ := client.Get(name); v != "b" {
+		t.Fatalf("expected b to keep the lock, got %q", v)
+	}
+	store.Delete(context.Background(), name)
+	tick(clock, client)
+	ownership(t, events, OwnershipRecovered)
+
+	lock.Release()
+	ownership(t, events, OwnershipReleased)
+}
+
+// slowStore takes rtt of clock time to acquire locks.
+type slowStore struct {
+	MemoryStore
+	clock *clocktest.Clock
+	rtt   time.Duration
+}
+
+func (s *slowStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	s.clock.Advance(s.rtt / 2)
+	err := s.MemoryStore.AcquireOrFreshenLock(ctx, name, value)
+	s.clock.Advance(s.rtt / 2)
+	return err
+}
+
+func TestHandleValidUntil(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &slowStore{MemoryStore: MemoryStore{TTL: 10, Clock: clock}, clock: clock, rtt: 2 * time.Second}
+	client := Client{Store: store, Clock: clock, Drift: 0.1}
+
+	start := clock.Now()
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	// the lease started within the round trip, 1s of drift is allowed
+	if until := lock.ValidUntil(); !until.Equal(start.Add(9 * time.Second)) {
+		t.Errorf("expected valid until %s, got %s", start.Add(9*time.Second), until)
+	}
+	if !lock.StillValid() {
+		t.Error("expected the lock to be valid")
+	}
+
+	// freshened by the Client, from when it asked
+	tick(clock, client)
+	clock.BlockUntil(1)
+	if until := lock.ValidUntil(); !until.Equal(start.Add(11500 * time.Millisecond)) {
+		t.Errorf("expected valid until %s once freshened, got %s", start.Add(11500*time.Millisecond), until)
+	}
+}
+
+func TestClientDrift(t *testing.T) {
+	for drift, want := range map[float64]float64{0: 0.01, 0.1: 0.1, -1: 0} {
+		if got := (Client{Drift: drift}).drift(); got != want {
+			t.Errorf("Drift %v: expected %v set aside, got %v", drift, want, got)
+		}
+	}
+}
+
+func TestHandleStillValid(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &ChaosStore{Store: &MemoryStore{TTL: 5, Clock: clock}, PartitionHang: time.Nanosecond}
+	client := Client{Store: store, Clock: clock, TTL: 5 * time.Second}
+
+	lock, err := client.Acquire(name, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// it can't be freshened, it's valid until 1% short of the TTL
+	store.Partition()
+	for i := 0; i < 9; i++ {
+		tick(clock, client)
+		if !lock.StillValid() {
+			t.Fatalf("expected the lock to be valid after %s", time.Duration(i+1)*client.refreshInterval())
+		}
+	}
+	clock.BlockUntil(1)
+	clock.Advance(450 * time.Millisecond)
+	if lock.StillValid() {
+		t.Error("expected the lock not to be valid past its TTL less the drift")
+	}
+
+	store.Heal()
+	lock.Release()
+	if !lock.ValidUntil().IsZero() || lock.StillValid() {
+		t.Error("expected a released lock not to be valid")
+	}
+}
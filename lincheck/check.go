@@ -0,0 +1,191 @@
+package lincheck
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// start anchors the timestamps of Recorders without a Clock.
+var start = time.Now()
+
+func monotonic() int64 {
+	return int64(time.Since(start))
+}
+
+// Result is the outcome of Check.
+type Result struct {
+	// Ok tells whether the history is linearizable.
+	Ok bool
+
+	// Name is the first lock, in name order, whose history isn't.
+	Name string
+
+	// History are the operations on that lock.
+	History []Operation
+}
+
+func (r Result) String() string {
+	if r.Ok {
+		return "linearizable"
+	}
+	lines := []string{fmt.Sprintf("history of %s isn't linearizable:", r.Name)}
+	for _, op := range r.History {
+		lines = append(lines, "  "+op.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check checks that history is linearizable against a sequential lock,
+// whose locks expire once ttl has passed since they were last acquired
+// or freshened. A zero ttl never expires locks.
+//
+// In the model an acquire is granted when the lock is free or held with
+// the same value, and denied otherwise; a release frees the lock; a get
+// answers the value of the lock. An acquire or release with an Unknown
+// outcome may or may not have taken effect.
+func Check(history []Operation, ttl time.Duration) Result {
+	byName := make(map[string][]Operation)
+	for _, op := range history {
+		byName[op.Name] = append(byName[op.Name], op)
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ops := byName[name]
+		if !linearizable(ops, int64(ttl)) {
+			sort.Slice(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+			return Result{Name: name, History: ops}
+		}
+	}
+	return Result{Ok: true}
+}
+
+// state is the state of the sequential lock: its holder, empty when it's
+// free, and since when, the call of the acquire which last took or
+// freshened it.
+type state struct {
+	holder string
+	since  int64
+}
+
+// step returns the states op can lead to from s, none if op can't be
+// applied to s.
+func step(s state, op Operation, ttl int64) []state {
+	// the lock may have expired before op took effect, which is at its
+	// return at the latest
+	from := []state{s}
+	if s.holder != "" && ttl > 0 && op.Return-s.since >= ttl {
+		from = append(from, state{})
+	}
+
+	var next []state
+	for _, s := range from {
+		switch op.Kind {
+		case Acquire:
+			granted := s.holder == "" || s.holder == op.Value
+			switch op.Outcome {
+			case Ok:
+				if granted {
+					next = append(next, state{op.Value, op.Call})
+				}
+			case Denied:
+				if !granted {
+					next = append(next, s)
+				}
+			case Unknown:
+				next = append(next, s)
+				if granted {
+					next = append(next, state{op.Value, op.Call})
+				}
+			}
+
+		case Release:
+			switch op.Outcome {
+			case Ok:
+				next = append(next, state{})
+			case Denied:
+				next = append(next, s)
+			case Unknown:
+				next = append(next, s, state{})
+			}
+
+		case Get:
+			switch op.Outcome {
+			case Ok:
+				if s.holder == op.Value && op.Value != "" {
+					next = append(next, s)
+				}
+			case Denied:
+				if s.holder == "" {
+					next = append(next, s)
+				}
+			}
+		}
+	}
+	return next
+}
+
+// linearizable searches, depth first, for an order of ops which respects
+// their real-time order and the model. An operation can come next when
+// it was called before every remaining operation returned. Pairs of
+// linearized operations and state already explored are remembered, so
+// they're only explored once.
+func linearizable(ops []Operation, ttl int64) bool {
+	n := len(ops)
+	done := make([]bool, n)
+	seen := make(map[string]bool)
+
+	var search func(s state, left int) bool
+	search = func(s state, left int) bool {
+		if left == 0 {
+			return true
+		}
+
+		key := memoKey(done, s)
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+
+		minReturn := int64(math.MaxInt64)
+		for i, op := range ops {
+			if !done[i] && op.Return < minReturn {
+				minReturn = op.Return
+			}
+		}
+
+		for i, op := range ops {
+			if done[i] || op.Call > minReturn {
+				continue
+			}
+			for _, next := range step(s, op, ttl) {
+				done[i] = true
+				ok := search(next, left-1)
+				done[i] = false
+				if ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	return search(state{}, n)
+}
+
+func memoKey(done []bool, s state) string {
+	b := make([]byte, (len(done)+7)/8, (len(done)+7)/8+len(s.holder)+24)
+	for i, d := range done {
+		if d {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return fmt.Sprintf("%s|%s|%d", b, s.holder, s.since)
+}
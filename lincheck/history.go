@@ -0,0 +1,202 @@
+// Package lincheck records histories of lock operations and checks that
+// they are linearizable against a sequential lock: that every lock was
+// only ever held by one owner at a time, however the operations of the
+// clients overlapped.
+//
+// Clients are given a Store recording their operations, then run
+// concurrently, and the history is checked afterwards:
+//
+//	rec := &lincheck.Recorder{}
+//	for i := 0; i < 8; i++ {
+//		client := locker.Client{Store: rec.Store(store, fmt.Sprint(i))}
+//		go work(client)
+//	}
+//	...
+//	if res := lincheck.Check(rec.History(), ttl); !res.Ok {
+//		t.Fatal(res)
+//	}
+//
+// Locks are checked one by one, as locks with different names don't
+// affect each other. The checker searches for an order of the operations
+// which respects their real-time order and the sequential model, like
+// Porcupine does; histories of a few hundred operations per lock check
+// quickly.
+package lincheck
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// Kind is the kind of an operation.
+type Kind int
+
+const (
+	// Acquire is an AcquireOrFreshenLock.
+	Acquire Kind = iota
+	// Release is a Delete.
+	Release
+	// Get is a Get.
+	Get
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Acquire:
+		return "acquire"
+	case Release:
+		return "release"
+	case Get:
+		return "get"
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// Outcome is what an operation answered.
+type Outcome int
+
+const (
+	// Ok is a granted acquire, a release, or a get which found the lock.
+	Ok Outcome = iota
+	// Denied is an acquire denied as the lock was held, or a get which
+	// didn't find it.
+	Denied
+	// Unknown is an operation which failed, and may or may not have
+	// taken effect.
+	Unknown
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Ok:
+		return "ok"
+	case Denied:
+		return "denied"
+	case Unknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("outcome(%d)", int(o))
+}
+
+// Operation is an operation in a history. Call and Return are when it
+// was invoked and when it completed, in nanoseconds of the Recorder's
+// clock; Return is math.MaxInt64 for operations whose outcome is
+// Unknown, as they could take effect any time later.
+type Operation struct {
+	Client  string
+	Kind    Kind
+	Name    string
+	Value   string
+	Outcome Outcome
+	Call    int64
+	Return  int64
+}
+
+func (op Operation) String() string {
+	ret := fmt.Sprint(op.Return)
+	if op.Return == math.MaxInt64 {
+		ret = "∞"
+	}
+	return fmt.Sprintf("[%d, %s] %s %s %s %q: %s", op.Call, ret, op.Client, op.Kind, op.Name, op.Value, op.Outcome)
+}
+
+// Recorder records the operations made through the Stores it returns.
+type Recorder struct {
+	// Clock timestamps the operations. Default: the system clock.
+	Clock locker.Clock
+
+	mu      sync.Mutex
+	history []Operation
+}
+
+// Store returns a Store which makes its operations on store, and records
+// them as client's.
+func (r *Recorder) Store(store locker.Store, client string) locker.Store {
+	return &recordingStore{store: store, rec: r, client: client}
+}
+
+// History returns the operations recorded so far.
+func (r *Recorder) History() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Operation(nil), r.history...)
+}
+
+func (r *Recorder) now() int64 {
+	if r.Clock == nil {
+		return monotonic()
+	}
+	return r.Clock.Now().UnixNano()
+}
+
+func (r *Recorder) record(op Operation) {
+	if op.Outcome == Unknown {
+		op.Return = math.MaxInt64
+	}
+	r.mu.Lock()
+	r.history = append(r.history, op)
+	r.mu.Unlock()
+}
+
+type recordingStore struct {
+	store  locker.Store
+	rec    *Recorder
+	client string
+}
+
+func (s *recordingStore) Get(ctx context.Context, name string) (string, error) {
+	op := Operation{Client: s.client, Kind: Get, Name: name, Call: s.rec.now()}
+	v, err := s.store.Get(ctx, name)
+	op.Return = s.rec.now()
+
+	switch err.(type) {
+	case nil:
+		op.Value, op.Outcome = v, Ok
+	case locker.LockNotFound:
+		op.Outcome = Denied
+	default:
+		// a failed get tells nothing
+		return v, err
+	}
+	s.rec.record(op)
+	return v, err
+}
+
+func (s *recordingStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	op := Operation{Client: s.client, Kind: Acquire, Name: name, Value: value, Call: s.rec.now()}
+	err := s.store.AcquireOrFreshenLock(ctx, name, value)
+	op.Return = s.rec.now()
+
+	switch err.(type) {
+	case nil:
+		op.Outcome = Ok
+	case locker.LockDenied:
+		op.Outcome = Denied
+	default:
+		op.Outcome = Unknown
+	}
+	s.rec.record(op)
+	return err
+}
+
+func (s *recordingStore) Delete(ctx context.Context, name string) error {
+	op := Operation{Client: s.client, Kind: Release, Name: name, Call: s.rec.now()}
+	err := s.store.Delete(ctx, name)
+	op.Return = s.rec.now()
+
+	switch err.(type) {
+	case nil:
+		op.Outcome = Ok
+	case locker.LockDenied:
+		op.Outcome = Denied
+	default:
+		op.Outcome = Unknown
+	}
+	s.rec.record(op)
+	return err
+}
@@ -0,0 +1,206 @@
+package lincheck
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/etcdtest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(etcdtest.Run(m))
+}
+
+func op(client string, kind Kind, value string, outcome Outcome, call, ret int64) Operation {
+	if outcome == Unknown {
+		ret = math.MaxInt64
+	}
+	return Operation{Client: client, Kind: kind, Name: "l", Value: value, Outcome: outcome, Call: call, Return: ret}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		history []Operation
+		ok      bool
+	}{
+		{"sequential", 0, []Operation{
+			op("a", Acquire, "a", Ok, 0, 1),
+			op("b", Acquire, "b", Denied, 2, 3),
+			op("b", Get, "a", Ok, 4, 5),
+			op("a", Release, "", Ok, 6, 7),
+			op("b", Acquire, "b", Ok, 8, 9),
+		}, true},
+		{"two owners", 0, []Operation{
+			op("a", Acquire, "a", Ok, 0, 1),
+			op("b", Acquire, "b", Ok, 2, 3),
+		}, false},
+		{"concurrent acquires, one granted", 0, []Operation{
+			op("a", Acquire, "a", Ok, 0, 10),
+			op("b", Acquire, "b", Denied, 1, 5),
+			op("b", Get, "a", Ok, 6, 7),
+		}, true},
+		{"granted after expiry", 10, []Operation{
+			op("a", Acquire, "a", Ok, 0, 1),
+			op("b", Acquire, "b", Ok, 20, 21),
+		}, true},
+		{"granted before expiry", 10, []Operation{
+			op("a", Acquire, "a", Ok, 0, 1),
+			op("b", Acquire, "b", Ok, 5, 6),
+		}, false},
+		{"freshened", 10, []Operation{
+			op("a", Acquire, "a", Ok, 0, 1),
+			op("a", Acquire, "a", Ok, 8, 9),
+			op("b", Acquire, "b", Ok, 12, 13),
+		}, false},
+		{"stale get", 0, []Operation{
+			op("a", Acquire, "a", Ok, 0, 1),
+			op("a", Release, "", Ok, 2, 3),
+			op("b", Get, "a", Ok, 4, 5),
+		}, false},
+		{"unknown acquire taking effect", 0, []Operation{
+			op("a", Acquire, "a", Unknown, 0, 1),
+			op("b", Acquire, "b", Denied, 5, 6),
+		}, true},
+		{"unknown acquire not taking effect", 0, []Operation{
+			op("a", Acquire, "a", Unknown, 0, 1),
+			op("b", Acquire, "b", Ok, 5, 6),
+		}, true},
+		{"unknown release", 0, []Operation{
+			op("a", Acquire, "a", Ok, 0, 1),
+			op("a", Release, "", Unknown, 2, 3),
+			op("b", Get, "a", Ok, 4, 5),
+			op("b", Get, "", Denied, 6, 7),
+		}, true},
+	}
+
+	for _, test := range tests {
+		res := Check(test.history, test.ttl)
+		if res.Ok != test.ok {
+			t.Errorf("%s: got %s", test.name, res)
+		}
+	}
+}
+
+// stress runs clients taking, checking and releasing a few locks on
+// store, and checks the history.
+func stress(t *testing.T, store locker.Store, clients, rounds int) Result {
+	rec := &Recorder{}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		id := fmt.Sprint(c)
+		s := rec.Store(store, id)
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				name := fmt.Sprintf("%s-%d", t.Name(), (c+i)%3)
+				if s.AcquireOrFreshenLock(ctx, name, id) != nil {
+					s.Get(ctx, name)
+					continue
+				}
+				s.Get(ctx, name)
+				s.Delete(ctx, name)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return Check(rec.History(), 30*time.Second)
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func() locker.Store{
+		"Memory": func() locker.Store { return &locker.MemoryStore{TTL: 30} },
+		"Sharded": func() locker.Store {
+			return &locker.ShardedStore{Shards: []locker.Shard{
+				{Name: "a", Store: &locker.MemoryStore{TTL: 30}},
+				{Name: "b", Store: &locker.MemoryStore{TTL: 30}},
+			}}
+		},
+		"Chaos": func() locker.Store {
+			return &locker.ChaosStore{
+				Store:      &locker.MemoryStore{TTL: 30},
+				Seed:       1,
+				ErrorRates: map[string]float64{"AcquireOrFreshenLock": 0.1, "Delete": 0.1},
+			}
+		},
+	}
+	// QuorumStore isn't checked: its Delete isn't atomic across the
+	// members, so a release overlapping an acquire can clear members the
+	// acquire was granted on, and the lock be granted again.
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if res := stress(t, store(), 8, 50); !res.Ok {
+				t.Error(res)
+			}
+		})
+	}
+}
+
+func TestEtcdStore(t *testing.T) {
+	client, err := locker.New(etcdtest.Endpoints(t), 5, 30, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := stress(t, client.Store, 8, 20); !res.Ok {
+		t.Error(res)
+	}
+}
+
+// racyStore checks whether a lock is free and takes it in two steps.
+// Acquires wait for each other between the steps, so every pair of them
+// both take the lock.
+type racyStore struct {
+	locker.MemoryStore
+
+	mu    sync.Mutex
+	locks map[string]string
+	gate  sync.WaitGroup
+}
+
+func (s *racyStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	s.mu.Lock()
+	v, held := s.locks[name]
+	s.mu.Unlock()
+	if held && v != value {
+		return locker.LockDenied{}
+	}
+
+	s.gate.Done()
+	s.gate.Wait()
+
+	s.mu.Lock()
+	s.locks[name] = value
+	s.mu.Unlock()
+	return nil
+}
+
+func TestDetectsRacyStore(t *testing.T) {
+	store := &racyStore{locks: make(map[string]string)}
+	store.gate.Add(2)
+
+	rec := &Recorder{}
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			rec.Store(store, id).AcquireOrFreshenLock(context.Background(), "l", id)
+		}(id)
+	}
+	wg.Wait()
+
+	if res := Check(rec.History(), 0); res.Ok {
+		t.Error("expected the racy store to grant the lock twice")
+	}
+}
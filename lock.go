@@ -1,7 +1,7 @@
 package locker
 
 import (
-	"errors"
+	"context"
 	"time"
 )
 
@@ -15,10 +15,15 @@ const (
 
 const DefaultValue = "ok"
 
+// refreshInterval is how often held locks are freshened, at most.
+const refreshInterval = 500 * time.Millisecond
+
 // Lock will create a lock for a key and set its value. If the owned
 // channel is provided a bool will be pushed whenever our ownership of
 // the lock changes. Pushing true into the quit channel will stop the
-// locker from refreshing the lock and let it expire if we own it.
+// locker from refreshing the lock and let it expire if we own it. A lock
+// which is lost, to somebody else or to expiry, stops being refreshed;
+// use Acquire to be told.
 //
 //     owned := make(chan bool)
 //
@@ -33,58 +38,167 @@ const DefaultValue = "ok"
 //
 // Lock is a blocking call, so it's recommended to run it in a goroutine.
 func (c Client) Lock(name, value string, quit <-chan bool) Report {
+	c, span := c.trace("Lock", name)
+	start := time.Now()
 	report := c.Inspect(name)
 
-	if report.Msg == Success {
-		var doneCh = make(chan bool)
+	attempts := 0
+	if report.Msg == Fail {
+		c.metrics().Acquire(name, time.Since(start), LockDenied{name})
+		c.logger().Log(LevelDebug, "lock is held",
+			Field{FieldOp, "Lock"}, Field{FieldKey, name}, Field{FieldLatency, time.Since(start)})
+	} else {
+		attempts++
+		var doneCh = make(chan error)
 		go c.lock(name, value, quit, doneCh)
-		var done = <-doneCh
-		if done {
-			return report
+		switch err := (<-doneCh).(type) {
+		case nil:
+		case LockDenied:
+			// somebody beat us to it since the inspection
+			report.Msg = Fail
+		default:
+			report.Err = err
 		}
-		report.Err = errors.New("error happened")
 	}
 
+	endLock(span, report, start, attempts)
 	return report
 }
 
-func (c Client) lock(name, value string, quit <-chan bool, done chan<- bool) error {
-	l("lock", "Update")
+func (c Client) lock(name, value string, quit <-chan bool, done chan<- error) {
+	start := c.clock().Now()
 	state, err := c.updateNode(name, value)
 	if err != nil {
-		return err
+		done <- err
+		return
+	}
+	if state == released {
+		done <- LockDenied{name}
+		return
 	}
-	lastState := state
-	tick := time.Tick(time.Millisecond * 500)
 
-	l("lock", "Done")
-	done <- true
+	done <- nil
+	c.hold(name, value, start, quit, nil)
+}
+
+// hold freshens a lock the Client acquired at start until quit or the
+// Client's context is done. The lock is lost when it's denied to the
+// Client, or expires because it couldn't be freshened within the TTL;
+// h, if it's set, is told, and recovers the lock as the Client's
+// Recovery says. Without h, hold stops once the lock is lost.
+func (c Client) hold(name, value string, start time.Time, quit <-chan bool, h *Handle) {
+	recovery := GiveUp
+	if h != nil {
+		recovery = c.Recovery
+	}
 
+	held, freshened := true, start
 	for {
 		select {
 		case <-quit:
-			return nil
-		case <-tick:
-			if lastState != state {
-				lastState = state
+			return
+		case <-c.context().Done():
+			return
+		case <-c.clock().After(c.refreshInterval()):
+		}
+		now := c.clock().Now()
+
+		if !held {
+			state, err := c.updateNode(name, value)
+			switch {
+			case err != nil:
+				// the Store may answer the next time
+			case state == acquired:
+				held, freshened = true, now
+				h.recover(now)
+			case recovery == ReacquireIfFree:
+				h.abandon(LockDenied{name})
+				return
 			}
+			continue
+		}
 
+		if ttl := c.ttl(); ttl > 0 && now.Sub(freshened) >= ttl {
+			c.metrics().Lost(name)
+			c.logger().Log(LevelWarn, "lock lost", Field{FieldOp, "Lock"}, Field{FieldKey, name},
+				Field{FieldError, "expired"})
+			held = false
+			h.lose(LockLost{name, "expired"})
+			if recovery == GiveUp {
+				return
+			}
+			continue
 		}
-	}
 
-	panic("unreachable")
+		switch err := c.Refresh(name, value).(type) {
+		case nil:
+			freshened = now
+			h.freshen(now)
+		case LockDenied:
+			held = false
+			h.lose(LockLost{name, "denied"})
+			switch recovery {
+			case GiveUp:
+				return
+			case ReacquireIfFree:
+				h.abandon(err)
+				return
+			}
+		default:
+			// the Store may answer the next time, as long as the lock
+			// hasn't expired
+		}
+	}
 }
 
+// Unlock stops refreshing the lock by pushing into quit, and releases it.
+// quit may be nil to release a lock held by another process.
 func (c Client) Unlock(name string, quit chan<- bool) error {
-	quit <- true
-	return c.Store.Delete(c.ctx, name)
+	c, span := c.trace("Unlock", name)
+
+	if quit != nil {
+		quit <- true
+	}
+	err := c.traceStore("Delete", name, func(ctx context.Context) error {
+		return c.Store.Delete(ctx, name)
+	})
+	if err != nil {
+		return endSpan(span, c.storeError("Delete", name, err))
+	}
+	c.metrics().Release(name)
+	c.logger().Log(LevelInfo, "lock released", Field{FieldOp, "Unlock"}, Field{FieldKey, name})
+	return endSpan(span, nil)
+}
+
+// Refresh freshens a lock the Client holds with value. LockDenied is
+// returned if the lock has been lost to somebody else meanwhile.
+func (c Client) Refresh(name, value string) error {
+	c, span := c.trace("Refresh", name)
+
+	err := c.traceStore("AcquireOrFreshenLock", name, func(ctx context.Context) error {
+		return c.Store.AcquireOrFreshenLock(ctx, name, value)
+	})
+	if _, lost := err.(LockDenied); lost {
+		c.metrics().Lost(name)
+		c.logger().Log(LevelWarn, "lock lost", Field{FieldOp, "Refresh"}, Field{FieldKey, name})
+	}
+	return endSpan(span, c.storeError("AcquireOrFreshenLock", name, err))
 }
 
 // updateNode will update the lock node in the cluster, effectively just
 // updating the TTL of the key and ensuring our value is still in it.
 func (c Client) updateNode(name, value string) (lockState, error) {
-	if err := c.Store.AcquireOrFreshenLock(c.ctx, name, value); err != nil {
+	start := time.Now()
+	err := c.traceStore("AcquireOrFreshenLock", name, func(ctx context.Context) error {
+		return c.Store.AcquireOrFreshenLock(ctx, name, value)
+	})
+	took := time.Since(start)
+	c.metrics().Acquire(name, took, err)
+	if err != nil {
+		c.storeError("AcquireOrFreshenLock", name, err)
 		if _, ok := err.(LockDenied); ok {
+			c.logger().Log(LevelDebug, "lock is held",
+				Field{FieldOp, "Lock"}, Field{FieldKey, name}, Field{FieldLatency, took})
 			return released, nil
 		}
 
@@ -92,5 +206,7 @@ func (c Client) updateNode(name, value string) (lockState, error) {
 		return unknown, err
 	}
 
+	c.logger().Log(LevelInfo, "lock acquired",
+		Field{FieldOp, "Lock"}, Field{FieldKey, name}, Field{FieldLatency, took})
 	return acquired, nil
 }
@@ -0,0 +1,10 @@
+// Package lockerpb holds the protobuf messages and gRPC stubs of the
+// locker gRPC API, as defined in locker.proto.
+//
+// locker.pb.go is generated with protoc and protoc-gen-go v1.0.0, the
+// release of github.com/golang/protobuf which is vendored:
+//
+//     go generate ./lockerpb
+package lockerpb
+
+//go:generate protoc --go_out=plugins=grpc:. locker.proto
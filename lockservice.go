@@ -18,6 +18,43 @@ type Client struct {
 	// Store is what locker uses to persist locks.
 	Store Store
 
+	// Metrics, if set, receives measurements of the Client's locks.
+	Metrics Metrics
+
+	// Tracer, if set, traces the Client's calls and their Store
+	// operations.
+	Tracer Tracer
+
+	// Logger, if set, receives the log events of the Client.
+	Logger Logger
+
+	// Clock paces the Client's lock and watch polling loops.
+	// Default: the system clock.
+	Clock Clock
+
+	// TTL is the time-to-live of the locks in the Store. A held lock
+	// which couldn't be freshened for that long is taken as lost.
+	// Default: the TTL of the Store, for the Stores of this package.
+	TTL time.Duration
+
+	// Drift is the share of the TTL set aside for clock drift between
+	// the Client and the Store when telling how long a Handle's lock is
+	// valid for. Default: 0.01. As zero is the default, a negative Drift
+	// sets none aside.
+	Drift float64
+
+	// Recovery is what the Handles of the Client do once their lock is
+	// lost. Default: GiveUp.
+	Recovery Recovery
+
+	// Breaker, if set, fails the Client's calls straight away while the
+	// Store is failing.
+	Breaker *Breaker
+
+	// Bulkhead, if set, limits the Store operations the Client has in
+	// flight.
+	Bulkhead *Bulkhead
+
 	ctx context.Context
 }
 
@@ -44,20 +81,58 @@ func New(machines []string, timeout int64, ttl int64, ctx context.Context) (Clie
 	}, nil
 }
 
+// context returns the context the Client was created with, so a Client
+// built as a literal around a Store still hands its Store a usable one.
+func (c Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 // Get returns the value of a lock. LockNotFound will be returned if a
 // lock with the name isn't held.
 func (c Client) Get(name string) (string, error) {
-	return c.Store.Get(c.ctx, name)
+	c, span := c.trace("Get", name)
+
+	var v string
+	err := c.traceStore("Get", name, func(ctx context.Context) (err error) {
+		v, err = c.Store.Get(ctx, name)
+		return err
+	})
+	return v, endSpan(span, c.storeError("Get", name, err))
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values. The Store has to be a Lister, otherwise Unsupported is
+// returned.
+func (c Client) List(prefix string) (map[string]string, error) {
+	lister, ok := c.Store.(Lister)
+	if !ok {
+		return nil, Unsupported{"List"}
+	}
+	var locks map[string]string
+	err := c.traceStore("List", prefix, func(ctx context.Context) (err error) {
+		locks, err = lister.List(ctx, prefix)
+		return err
+	})
+	return locks, c.storeError("List", prefix, err)
 }
 
 func (c Client) Inspect(name string) Report {
+	c, span := c.trace("Inspect", name)
+
 	v, err := c.Get(name)
 	if err == nil && len(v) > 0 {
+		span.SetAttributes(Attribute{AttrOutcome, OutcomeDenied})
+		span.End(nil)
 		return Report{
 			Err: nil,
 			Msg: Fail,
 		}
 	}
+	span.SetAttributes(Attribute{AttrOutcome, OutcomeOK})
+	span.End(nil)
 	return Report{
 		Err: nil,
 		Msg: Success,
@@ -70,8 +145,9 @@ type Report struct {
 }
 
 // Store is a persistance mechaism for locker to store locks. Needs to be
-// able to support querying and an atomic compare-and-swap. Currently, the
-// only implementation of a Store is EtcdStore.
+// able to support querying and an atomic compare-and-swap. EtcdStore is
+// the default implementation, EtcdV2Store supports clusters only serving
+// the v2 API and FileStore keeps locks on the local host.
 type Store interface {
 	// Get returns the value of a lock. LockNotFound will be returned if a
 	// lock with the name isn't held.
@@ -83,3 +159,57 @@ type Store interface {
 
 	Delete(ctx context.Context, name string) error
 }
+
+// Watcher is implemented by Stores which can push changes to a lock
+// themselves, rather than Client.Watch having to poll for them.
+type Watcher interface {
+	// Watch pushes the value of the named lock into valueChanges, and
+	// then every change to it, until ctx is done. An empty string
+	// indicates the lack of a lock.
+	Watch(ctx context.Context, name string, valueChanges chan<- string) error
+}
+
+// Lister is implemented by Stores which can enumerate the locks they
+// hold.
+type Lister interface {
+	// List returns the held locks whose names start with prefix, mapped
+	// to their values.
+	List(ctx context.Context, prefix string) (map[string]string, error)
+}
+
+// CompareAndDeleter is implemented by Stores which can release a lock
+// only while it's held with a given value, atomically.
+type CompareAndDeleter interface {
+	// CompareAndDelete releases the named lock if it's held with value.
+	// LockNotFound is returned if the lock isn't held, LockDenied if
+	// it's held by another value.
+	CompareAndDelete(ctx context.Context, name, value string) error
+}
+
+// compareAndDelete releases the named lock of store if it's held with
+// value, with CompareAndDelete if store is a CompareAndDeleter. Other
+// Stores are asked for the value first, and a lock changing hands
+// between that and the Delete is released all the same.
+func compareAndDelete(ctx context.Context, store Store, name, value string) error {
+	if deleter, ok := store.(CompareAndDeleter); ok {
+		return deleter.CompareAndDelete(ctx, name, value)
+	}
+
+	v, err := store.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if v != value {
+		return LockDenied{name}
+	}
+	return store.Delete(ctx, name)
+}
+
+// Transferer is implemented by Stores which can hand a lock from one
+// holder to another atomically.
+type Transferer interface {
+	// Transfer gives the named lock held by from to to, with a fresh
+	// TTL. LockNotFound is returned if the lock isn't held, LockDenied
+	// if it's held by another value than from.
+	Transfer(ctx context.Context, name, from, to string) error
+}
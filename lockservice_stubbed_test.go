@@ -4,13 +4,16 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/PumpkinSeed/locker/clocktest"
 )
 
 const name = "myservice"
 
 func TestWatch(t *testing.T) {
 	store := &memoryStore{}
-	client := Client{store, context.Background()}
+	clock := clocktest.New(time.Now())
+	client := Client{Store: store, Clock: clock, ctx: context.Background()}
 
 	valueChanges := make(chan string)
 	quit := make(chan bool)
@@ -27,6 +30,8 @@ func TestWatch(t *testing.T) {
 	}
 
 	store.set(name, "value")
+	clock.BlockUntil(1)
+	clock.Advance(3 * time.Second)
 
 	select {
 	case <-timeout():
@@ -5,19 +5,23 @@ import (
 	"fmt"
 	_ "fmt"
 	"math/rand"
+	"os"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/PumpkinSeed/locker/etcdtest"
 	"github.com/coreos/go-etcd/etcd"
 )
 
-var machines = []string{"http://127.0.0.1:2379", "http://127.0.0.1:2380", "http://127.0.0.1:2381"}
-
 var etcdclient *etcd.Client
 
+func TestMain(m *testing.M) {
+	os.Exit(etcdtest.Run(m))
+}
+
 func TestTestLockAndUnlock(t *testing.T) {
-	client, err := New(machines, 5, 5, context.Background())
+	client, err := New(etcdtest.Endpoints(t), 5, 5, context.Background())
 	if err != nil {
 		t.Error(err)
 	}
@@ -56,7 +60,7 @@ func TestTestLockAndUnlock(t *testing.T) {
 
 func TestTTL(t *testing.T) {
 	ttl := int64(3)
-	client, err := New(machines, 3, ttl, context.Background())
+	client, err := New(etcdtest.Endpoints(t), 3, ttl, context.Background())
 	if err != nil {
 		t.Error(err)
 	}
@@ -72,6 +76,8 @@ func TestTTL(t *testing.T) {
 		t.Errorf("Report message should be '%s', instead of %s", Success, report.Msg)
 	}
 
+	// stop refreshing it and let it expire
+	quit <- true
 	time.Sleep(time.Duration(ttl*1000+500) * time.Millisecond)
 
 	report = client.Lock(key, DefaultValue, quit)
@@ -86,7 +92,7 @@ func TestTTL(t *testing.T) {
 }
 
 func BenchmarkLockAndUnlock(b *testing.B) {
-	client, err := New(machines, 3, 3, context.Background())
+	client, err := New(etcdtest.Endpoints(b), 3, 3, context.Background())
 	if err != nil {
 		b.Error(err)
 	}
@@ -100,7 +106,7 @@ func BenchmarkLockAndUnlock(b *testing.B) {
 	}
 }
 func BenchmarkLock(b *testing.B) {
-	client, err := New(machines, 3, 3, context.Background())
+	client, err := New(etcdtest.Endpoints(b), 3, 3, context.Background())
 	if err != nil {
 		b.Error(err)
 	}
@@ -0,0 +1,137 @@
+package locker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-log/log"
+)
+
+// Level is the severity of a log event.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Field is a key and value carried by a log event.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// The keys of the fields Clients and Stores log with.
+const (
+	// FieldOp is the operation, the name of the Client or Store method.
+	FieldOp = "op"
+
+	// FieldKey is the name of the lock.
+	FieldKey = "key"
+
+	// FieldLease is the etcd lease of the lock.
+	FieldLease = "lease"
+
+	// FieldMember is the index of a member of a QuorumStore.
+	FieldMember = "member"
+
+	// FieldLatency is how long the operation took, a time.Duration.
+	FieldLatency = "latency"
+
+	// FieldError is the error the operation failed with.
+	FieldError = "error"
+)
+
+// Logger receives the log events of Clients and Stores. Set it on a
+// Client, or as the Log of a Store of this package, to get them; NewGoLog
+// adapts a go-log Logger, and package slogger a log/slog one. Loggers
+// are called from several goroutines.
+//
+//     client := locker.Client{Store: store, Logger: locker.NewGoLog(log.New("locker", false, sink))}
+//
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+// noLogger is used by Clients without a Logger.
+type noLogger struct{}
+
+func (noLogger) Log(Level, string, ...Field) {}
+
+func (c Client) logger() Logger {
+	if c.Logger == nil {
+		return noLogger{}
+	}
+	return c.Logger
+}
+
+// logStore sends the debug event of an operation of a Store, which
+// started at start and failed with err if it isn't nil, to the Logger of
+// the store. Nothing is sent if it hasn't one.
+func logStore(l Logger, store, op, name string, start time.Time, err error, fields ...Field) {
+	if l == nil {
+		return
+	}
+	fields = append([]Field{{FieldOp, op}, {FieldKey, name}, {FieldLatency, time.Since(start)}}, fields...)
+	if err != nil {
+		fields = append(fields, Field{FieldError, err})
+	}
+	l.Log(LevelDebug, store+" "+op, fields...)
+}
+
+// goLog adapts a go-log Logger.
+type goLog struct {
+	l *log.Logger
+}
+
+// NewGoLog returns a Logger logging to l. Levels map onto the go-log
+// priorities of the same name, and fields are appended to the message as
+// key=value pairs.
+func NewGoLog(l *log.Logger) Logger {
+	return goLog{l}
+}
+
+func (g goLog) Log(level Level, msg string, fields ...Field) {
+	priority := log.PriDebug
+	switch level {
+	case LevelInfo:
+		priority = log.PriInfo
+	case LevelWarn:
+		priority = log.PriWarning
+	case LevelError:
+		priority = log.PriErr
+	}
+	g.l.Log(priority, formatFields(msg, fields))
+}
+
+// formatFields appends fields to msg as key=value pairs, quoting values
+// with spaces.
+func formatFields(msg string, fields []Field) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, f := range fields {
+		v := fmt.Sprint(f.Value)
+		if strings.ContainsAny(v, " \"=") || v == "" {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", f.Key, v)
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/coreos/go-log/log"
+)
+
+type logEvent struct {
+	level  Level
+	msg    string
+	fields map[string]interface{}
+}
+
+// logRecorder is a Logger which records the events it gets.
+type logRecorder struct {
+	mu     sync.Mutex
+	events []logEvent
+}
+
+func (r *logRecorder) Log(level Level, msg string, fields ...Field) {
+	ev := logEvent{level, msg, make(map[string]interface{})}
+	for _, f := range fields {
+		ev.fields[f.Key] = f.Value
+	}
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	r.mu.Unlock()
+}
+
+func (r *logRecorder) find(t *testing.T, msg string) logEvent {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ev := range r.events {
+		if ev.msg == msg {
+			return ev
+		}
+	}
+	t.Fatalf("no %q event in %v", msg, r.events)
+	return logEvent{}
+}
+
+// failingStore fails every operation.
+type failingStore struct {
+	memoryStore
+}
+
+func (*failingStore) Delete(ctx context.Context, name string) error {
+	return errors.New("store down")
+}
+
+func TestClientLogs(t *testing.T) {
+	logs := &logRecorder{}
+	client := Client{Store: &memoryStore{}, Logger: logs}
+
+	quit := make(chan bool)
+	if report := client.Lock(name, "a", quit); report.Msg != Success {
+		t.Fatalf("lock: %+v", report)
+	}
+	if ev := logs.find(t, "lock acquired"); ev.level != LevelInfo || ev.fields[FieldKey] != name {
+		t.Errorf("acquired: %+v", ev)
+	}
+	if _, ok := logs.find(t, "lock acquired").fields[FieldLatency]; !ok {
+		t.Error("acquired without latency")
+	}
+
+	if report := (Client{Store: client.Store, Logger: logs}).Lock(name, "b", nil); report.Msg != Fail {
+		t.Fatalf("lock: %+v", report)
+	}
+	if ev := logs.find(t, "lock is held"); ev.level != LevelDebug {
+		t.Errorf("held: %+v", ev)
+	}
+
+	if err := client.Unlock(name, quit); err != nil {
+		t.Fatal(err)
+	}
+	if ev := logs.find(t, "lock released"); ev.level != LevelInfo || ev.fields[FieldOp] != "Unlock" {
+		t.Errorf("released: %+v", ev)
+	}
+
+	client.Store = &failingStore{}
+	client.Unlock(name, nil)
+	ev := logs.find(t, "store operation failed")
+	if ev.level != LevelError || ev.fields[FieldOp] != "Delete" || ev.fields[FieldError] == nil {
+		t.Errorf("store error: %+v", ev)
+	}
+}
+
+func TestStoreLogs(t *testing.T) {
+	logs := &logRecorder{}
+	ctx := context.Background()
+
+	quorum := &QuorumStore{Stores: []Store{&MemoryStore{}, &MemoryStore{}, &MemoryStore{}}, Log: logs}
+	if err := quorum.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+	ev := logs.find(t, "quorum AcquireOrFreshenLock")
+	if ev.level != LevelDebug || ev.fields[FieldKey] != name || ev.fields[FieldError] != nil {
+		t.Errorf("quorum: %+v", ev)
+	}
+	if _, ok := ev.fields[FieldLatency]; !ok {
+		t.Error("quorum event without latency")
+	}
+
+	sharded := &ShardedStore{Shards: []Shard{{"a", quorum}}, Log: logs}
+	sharded.AcquireOrFreshenLock(ctx, name, "b")
+	if ev := logs.find(t, "sharded AcquireOrFreshenLock"); ev.fields[FieldError] != (LockDenied{name}) {
+		t.Errorf("sharded: %+v", ev)
+	}
+}
+
+// sink records the messages go-log hands it.
+type sink struct {
+	fields []log.Fields
+}
+
+func (s *sink) Log(fields log.Fields) {
+	s.fields = append(s.fields, fields)
+}
+
+func TestGoLog(t *testing.T) {
+	s := &sink{}
+	l := NewGoLog(log.New("locker", false, s))
+
+	l.Log(LevelWarn, "lock lost", Field{FieldKey, "jobs/1"}, Field{FieldError, errors.New("store down")})
+
+	if len(s.fields) != 1 {
+		t.Fatalf("got %d messages", len(s.fields))
+	}
+	if p := s.fields[0]["priority"]; p != log.PriWarning {
+		t.Errorf("priority: %v", p)
+	}
+	msg := s.fields[0]["message"].(string)
+	if want := `lock lost key=jobs/1 error="store down"`; msg != want {
+		t.Errorf("got %q, want %q", msg, want)
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	for level, want := range map[Level]string{LevelDebug: "debug", LevelError: "error", Level(9): "level(9)"} {
+		if got := level.String(); got != want {
+			t.Errorf("%d: %s", level, got)
+		}
+	}
+}
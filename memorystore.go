@@ -0,0 +1,147 @@
+package locker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a backing store for Locker which keeps its locks in
+// memory. Locks are only shared between Clients using the same
+// MemoryStore, which makes it useful for tests and for running locker
+// inside a single process.
+type MemoryStore struct {
+	// TTL is the time-to-live for the lock in seconds. Default: 5s.
+	TTL int64
+
+	// Clock tells when locks expire. Default: the system clock.
+	Clock Clock
+
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	value   string
+	expires time.Time
+}
+
+// Get returns the value of a lock. LockNotFound will be returned if a
+// lock with the name isn't held.
+func (s *MemoryStore) Get(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.lookup(name)
+	if !ok {
+		return "", LockNotFound{name}
+	}
+	return lock.value, nil
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is.
+func (s *MemoryStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lock, ok := s.lookup(name); ok && lock.value != value {
+		return LockDenied{name}
+	}
+
+	if s.locks == nil {
+		s.locks = make(map[string]memoryLock)
+	}
+	s.locks[name] = memoryLock{
+		value:   value,
+		expires: orRealClock(s.Clock).Now().Add(time.Duration(s.lockTTL()) * time.Second),
+	}
+	return nil
+}
+
+// Delete releases the named lock, regardless of who holds it.
+func (s *MemoryStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.locks, name)
+	return nil
+}
+
+// CompareAndDelete releases the named lock if it's held with value.
+func (s *MemoryStore) CompareAndDelete(ctx context.Context, name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.lookup(name)
+	if !ok {
+		return LockNotFound{name}
+	}
+	if lock.value != value {
+		return LockDenied{name}
+	}
+	delete(s.locks, name)
+	return nil
+}
+
+// Transfer gives the named lock held by from to to, with a fresh TTL.
+func (s *MemoryStore) Transfer(ctx context.Context, name, from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.lookup(name)
+	if !ok {
+		return LockNotFound{name}
+	}
+	if lock.value != from {
+		return LockDenied{name}
+	}
+	s.locks[name] = memoryLock{
+		value:   to,
+		expires: orRealClock(s.Clock).Now().Add(time.Duration(s.lockTTL()) * time.Second),
+	}
+	return nil
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values.
+func (s *MemoryStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks := make(map[string]string)
+	for name := range s.locks {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if lock, ok := s.lookup(name); ok {
+			locks[name] = lock.value
+		}
+	}
+	return locks, nil
+}
+
+// lookup returns the named lock if it's held, dropping it if it has
+// expired. The caller must hold s.mu.
+func (s *MemoryStore) lookup(name string) (memoryLock, bool) {
+	lock, ok := s.locks[name]
+	if !ok {
+		return memoryLock{}, false
+	}
+	if !orRealClock(s.Clock).Now().Before(lock.expires) {
+		delete(s.locks, name)
+		return memoryLock{}, false
+	}
+	return lock, true
+}
+
+// lockTTL gets the TTL of the locks being stored in memory. Defaults to
+// 5 seconds.
+func (s *MemoryStore) lockTTL() int64 {
+	if s.TTL <= 0 {
+		return 5
+	}
+
+	return s.TTL
+}
@@ -0,0 +1,58 @@
+package locker
+
+import "time"
+
+// Metrics receives measurements of what a Client does. Set it on a
+// Client to collect them; package metrics has one exposing them to
+// Prometheus. Its methods are called synchronously, so they have to be
+// quick, and safe to call from several goroutines.
+type Metrics interface {
+	// Acquire is called after every attempt to acquire a lock, with how
+	// long it took and its outcome: nil, LockDenied or the error of the
+	// Store.
+	Acquire(name string, took time.Duration, err error)
+
+	// Release is called when a lock is released through the Client.
+	Release(name string)
+
+	// Lost is called when a held lock is found to be held by somebody
+	// else, or to have expired.
+	Lost(name string)
+
+	// StoreError is called when the Store fails an operation, other
+	// than with LockDenied or LockNotFound.
+	StoreError(op string, err error)
+
+	// WatchLag is called for every change a watch pushes, with how long
+	// it took to be received after it came from the Store.
+	WatchLag(name string, lag time.Duration)
+}
+
+// noMetrics is used by Clients without Metrics.
+type noMetrics struct{}
+
+func (noMetrics) Acquire(string, time.Duration, error) {}
+func (noMetrics) Release(string)                       {}
+func (noMetrics) Lost(string)                          {}
+func (noMetrics) StoreError(string, error)             {}
+func (noMetrics) WatchLag(string, time.Duration)       {}
+
+func (c Client) metrics() Metrics {
+	if c.Metrics == nil {
+		return noMetrics{}
+	}
+	return c.Metrics
+}
+
+// storeError reports err to the Metrics and the Logger of the Client if
+// it's a failure of the Store, and returns it.
+func (c Client) storeError(op, name string, err error) error {
+	switch err.(type) {
+	case nil, LockDenied, LockNotFound:
+	default:
+		c.metrics().StoreError(op, err)
+		c.logger().Log(LevelError, "store operation failed",
+			Field{FieldOp, op}, Field{FieldKey, name}, Field{FieldError, err})
+	}
+	return err
+}
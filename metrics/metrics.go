@@ -0,0 +1,324 @@
+// Package metrics collects the measurements of locker Clients for
+// Prometheus to scrape.
+//
+// The Collector is a prometheus.Collector, so it can be registered with
+// the registry the rest of a program's metrics are on:
+//
+//	collector := metrics.New(metrics.GroupByPrefix("/", 1))
+//	client.Metrics = collector
+//	prometheus.MustRegister(collector)
+//
+// It's an http.Handler as well, serving its metrics alone, for programs
+// without a registry of their own:
+//
+//	http.Handle("/metrics", collector)
+//
+// Lock names usually carry ids, so locks are counted by group instead:
+// Group maps every name onto a group, and past MaxGroups groups the rest
+// are counted under "other", which keeps the number of series bounded.
+//
+// The collector exports:
+//
+//	locker_acquire_attempts_total{group}      attempts to acquire a lock
+//	locker_acquire_successes_total{group}     attempts which got the lock
+//	locker_acquire_denials_total{group}       attempts denied, the lock was held
+//	locker_acquire_duration_seconds{group}    histogram of attempt latency
+//	locker_hold_duration_seconds{group}       histogram of how long locks were held
+//	locker_held_locks{group}                  locks held now
+//	locker_lost_locks_total{group}            held locks lost to somebody else
+//	locker_store_errors_total{op}             failed Store operations
+//	locker_watch_lag_seconds{group}           histogram of watch delivery lag
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// OtherGroup is the group locks are counted under once MaxGroups groups
+// are in use.
+const OtherGroup = "other"
+
+// DefaultBuckets are the histogram buckets, in seconds.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+// Collector is a locker.Metrics which keeps the measurements for
+// Prometheus, and a prometheus.Collector exporting them. Create it with
+// New.
+type Collector struct {
+	// Group maps a lock name onto the group it's counted under.
+	Group func(name string) string
+
+	// MaxGroups is how many groups are counted separately. Default: 100.
+	MaxGroups int
+
+	mu      sync.Mutex
+	groups  map[string]*groupMetrics
+	storeOp map[string]float64
+	holds   map[string]hold
+}
+
+// groupMetrics are the measurements of a group.
+type groupMetrics struct {
+	attempts  float64
+	successes float64
+	denials   float64
+	lost      float64
+	held      float64
+	acquire   *histogram
+	hold      *histogram
+	watchLag  *histogram
+}
+
+// hold is a lock held through a Client.
+type hold struct {
+	group string
+	since time.Time
+}
+
+var (
+	_ locker.Metrics       = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// New creates a Collector grouping lock names with group. A nil group
+// counts every lock in a single group, "all".
+func New(group func(name string) string) *Collector {
+	if group == nil {
+		group = func(string) string { return "all" }
+	}
+	return &Collector{
+		Group:   group,
+		groups:  make(map[string]*groupMetrics),
+		storeOp: make(map[string]float64),
+		holds:   make(map[string]hold),
+	}
+}
+
+// GroupByPrefix groups lock names by their first depth parts split by
+// sep: with ("/", 1) "jobs/1234" is counted under "jobs".
+func GroupByPrefix(sep string, depth int) func(string) string {
+	return func(name string) string {
+		parts := strings.SplitN(name, sep, depth+1)
+		if len(parts) > depth {
+			parts = parts[:depth]
+		}
+		return strings.Join(parts, sep)
+	}
+}
+
+// Acquire counts an attempt to acquire a lock.
+func (c *Collector) Acquire(name string, took time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, g := c.group(name)
+	g.attempts++
+	g.acquire.observe(took.Seconds())
+
+	switch err.(type) {
+	case nil:
+		g.successes++
+		if _, ok := c.holds[name]; !ok {
+			g.held++
+			c.holds[name] = hold{group: group, since: time.Now()}
+		}
+	case locker.LockDenied:
+		g.denials++
+	}
+}
+
+// Release ends the hold of a lock.
+func (c *Collector) Release(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.endHold(name)
+}
+
+// Lost counts a held lock lost, and ends its hold.
+func (c *Collector) Lost(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, g := c.group(name)
+	g.lost++
+	c.endHold(name)
+}
+
+// StoreError counts a failed Store operation.
+func (c *Collector) StoreError(op string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.storeOp[op]++
+}
+
+// WatchLag observes how long a watched change took to be received after
+// it came from the Store.
+func (c *Collector) WatchLag(name string, lag time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, g := c.group(name)
+	g.watchLag.observe(lag.Seconds())
+}
+
+// endHold observes the hold duration of a lock held through a Client.
+// The caller must hold c.mu.
+func (c *Collector) endHold(name string) {
+	h, ok := c.holds[name]
+	if !ok {
+		return
+	}
+	delete(c.holds, name)
+
+	g := c.groups[h.group]
+	g.held--
+	g.hold.observe(time.Since(h.since).Seconds())
+}
+
+// group returns the metrics of the group of name, creating them if
+// there's room. The caller must hold c.mu.
+func (c *Collector) group(name string) (string, *groupMetrics) {
+	group := c.Group(name)
+	if g, ok := c.groups[group]; ok {
+		return group, g
+	}
+
+	max := c.MaxGroups
+	if max <= 0 {
+		max = 100
+	}
+	if len(c.groups) >= max {
+		group = OtherGroup
+		if g, ok := c.groups[group]; ok {
+			return group, g
+		}
+	}
+
+	g := &groupMetrics{
+		acquire:  newHistogram(),
+		hold:     newHistogram(),
+		watchLag: newHistogram(),
+	}
+	c.groups[group] = g
+	return group, g
+}
+
+// Describe sends the descriptions of the metrics to ch, for
+// prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range descs {
+		ch <- desc
+	}
+}
+
+// Collect sends the metrics to ch, for prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for group, g := range c.groups {
+		ch <- prometheus.MustNewConstMetric(acquireAttemptsDesc, prometheus.CounterValue, g.attempts, group)
+		ch <- prometheus.MustNewConstMetric(acquireSuccessesDesc, prometheus.CounterValue, g.successes, group)
+		ch <- prometheus.MustNewConstMetric(acquireDenialsDesc, prometheus.CounterValue, g.denials, group)
+		ch <- prometheus.MustNewConstMetric(lostLocksDesc, prometheus.CounterValue, g.lost, group)
+		ch <- prometheus.MustNewConstMetric(heldLocksDesc, prometheus.GaugeValue, g.held, group)
+		ch <- g.acquire.metric(acquireDurationDesc, group)
+		ch <- g.hold.metric(holdDurationDesc, group)
+		ch <- g.watchLag.metric(watchLagDesc, group)
+	}
+	for op, errors := range c.storeOp {
+		ch <- prometheus.MustNewConstMetric(storeErrorsDesc, prometheus.CounterValue, errors, op)
+	}
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	promhttp.HandlerFor(c.registry(), promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	families, err := c.registry().Gather()
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	for _, family := range families {
+		n, err := expfmt.MetricFamilyToText(w, family)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// registry returns a registry of the Collector alone, which serves
+// ServeHTTP and WriteTo.
+func (c *Collector) registry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	return reg
+}
+
+var (
+	acquireAttemptsDesc  = newDesc("locker_acquire_attempts_total", "Attempts to acquire a lock.", "group")
+	acquireSuccessesDesc = newDesc("locker_acquire_successes_total", "Attempts to acquire a lock which got it.", "group")
+	acquireDenialsDesc   = newDesc("locker_acquire_denials_total", "Attempts to acquire a lock which were denied, the lock was held.", "group")
+	lostLocksDesc        = newDesc("locker_lost_locks_total", "Held locks which were lost to somebody else.", "group")
+	heldLocksDesc        = newDesc("locker_held_locks", "Locks held now.", "group")
+	acquireDurationDesc  = newDesc("locker_acquire_duration_seconds", "Latency of attempts to acquire a lock.", "group")
+	holdDurationDesc     = newDesc("locker_hold_duration_seconds", "How long locks were held.", "group")
+	watchLagDesc         = newDesc("locker_watch_lag_seconds", "How long watched changes took to be received after they came from the store.", "group")
+	storeErrorsDesc      = newDesc("locker_store_errors_total", "Failed Store operations.", "op")
+)
+
+// descs are the descriptions of every metric the Collector exports.
+var descs []*prometheus.Desc
+
+func newDesc(name, help, label string) *prometheus.Desc {
+	desc := prometheus.NewDesc(name, help, []string{label}, nil)
+	descs = append(descs, desc)
+	return desc
+}
+
+// histogram counts observations into cumulative buckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(DefaultBuckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range DefaultBuckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) metric(desc *prometheus.Desc, group string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(DefaultBuckets))
+	for i, upper := range DefaultBuckets {
+		buckets[upper] = h.counts[i]
+	}
+	return prometheus.MustNewConstHistogram(desc, h.count, h.sum, buckets, group)
+}
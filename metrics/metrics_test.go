@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type: %q", ct)
+	}
+	return rec.Body.String()
+}
+
+func expect(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := New(GroupByPrefix("/", 1))
+	client := locker.Client{Store: &locker.MemoryStore{}, Metrics: c}
+	other := locker.Client{Store: client.Store, Metrics: c}
+	ctx := context.Background()
+
+	quit1, quit2 := make(chan bool), make(chan bool)
+	defer close(quit1)
+	if r := client.Lock("jobs/1", "a", quit1); r.Msg != locker.Success {
+		t.Fatalf("jobs/1: %+v", r)
+	}
+	if r := client.Lock("jobs/2", "a", quit2); r.Msg != locker.Success {
+		t.Fatalf("jobs/2: %+v", r)
+	}
+	if r := other.Lock("jobs/1", "b", nil); r.Msg != locker.Fail {
+		t.Fatalf("expected jobs/1 to be denied: %+v", r)
+	}
+	if err := client.Unlock("jobs/2", quit2); err != nil {
+		t.Fatal(err)
+	}
+
+	// jobs/1 is taken over while client holds it
+	client.Store.Delete(ctx, "jobs/1")
+	client.Store.AcquireOrFreshenLock(ctx, "jobs/1", "b")
+	if err := client.Refresh("jobs/1", "a"); err == nil {
+		t.Fatal("expected jobs/1 to be lost")
+	}
+
+	expect(t, scrape(t, c),
+		`locker_acquire_attempts_total{group="jobs"} 3`,
+		`locker_acquire_successes_total{group="jobs"} 2`,
+		`locker_acquire_denials_total{group="jobs"} 1`,
+		`locker_lost_locks_total{group="jobs"} 1`,
+		`locker_held_locks{group="jobs"} 0`,
+		`locker_hold_duration_seconds_count{group="jobs"} 2`,
+		`locker_acquire_duration_seconds_bucket{group="jobs",le="+Inf"} 3`,
+	)
+}
+
+func TestStoreErrors(t *testing.T) {
+	c := New(nil)
+	c.StoreError("Get", errors.New("down"))
+	c.StoreError("Get", errors.New("down"))
+	c.StoreError("Delete", errors.New("down"))
+	c.Acquire("a", time.Millisecond, errors.New("down"))
+
+	expect(t, scrape(t, c),
+		`locker_store_errors_total{op="Delete"} 1`,
+		`locker_store_errors_total{op="Get"} 2`,
+		`locker_acquire_attempts_total{group="all"} 1`,
+		`locker_acquire_successes_total{group="all"} 0`,
+		`locker_held_locks{group="all"} 0`,
+	)
+}
+
+func TestHistogram(t *testing.T) {
+	c := New(nil)
+	c.WatchLag("a", 3*time.Millisecond)
+	c.WatchLag("a", 2*time.Second)
+
+	expect(t, scrape(t, c),
+		`locker_watch_lag_seconds_bucket{group="all",le="0.001"} 0`,
+		`locker_watch_lag_seconds_bucket{group="all",le="0.005"} 1`,
+		`locker_watch_lag_seconds_bucket{group="all",le="2.5"} 2`,
+		`locker_watch_lag_seconds_bucket{group="all",le="+Inf"} 2`,
+		`locker_watch_lag_seconds_sum{group="all"} 2.003`,
+		`locker_watch_lag_seconds_count{group="all"} 2`,
+	)
+}
+
+func TestMaxGroups(t *testing.T) {
+	c := New(func(name string) string { return name })
+	c.MaxGroups = 2
+	for _, name := range []string{"a", "b", "c", "d", "a"} {
+		c.Acquire(name, 0, locker.LockDenied{})
+	}
+
+	out := scrape(t, c)
+	expect(t, out,
+		`locker_acquire_denials_total{group="a"} 2`,
+		`locker_acquire_denials_total{group="b"} 1`,
+		`locker_acquire_denials_total{group="other"} 2`,
+	)
+	if strings.Contains(out, `group="c"`) {
+		t.Errorf("expected c counted as other:\n%s", out)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	c := New(func(name string) string { return name })
+	c.Acquire("a\"b\\c\nd", 0, nil)
+
+	expect(t, scrape(t, c), `locker_held_locks{group="a\"b\\c\nd"} 1`)
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	group := GroupByPrefix("/", 2)
+	for name, want := range map[string]string{
+		"a":       "a",
+		"a/b":     "a/b",
+		"a/b/c/d": "a/b",
+		"":        "",
+	} {
+		if got := group(name); got != want {
+			t.Errorf("%q: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	c := New(nil)
+	c.Acquire("a", 0, nil)
+
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("wrote %d of %d: %v", n, buf.Len(), err)
+	}
+	expect(t, buf.String(),
+		"# HELP locker_held_locks Locks held now.",
+		"# TYPE locker_held_locks gauge",
+		"# TYPE locker_acquire_duration_seconds histogram",
+	)
+}
+
+func TestRegistry(t *testing.T) {
+	c := New(nil)
+	c.Acquire("a", time.Millisecond, nil)
+	c.StoreError("Get", errors.New("down"))
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]int)
+	for _, family := range families {
+		got[family.GetName()] = len(family.GetMetric())
+	}
+	for _, name := range []string{
+		"locker_acquire_attempts_total",
+		"locker_held_locks",
+		"locker_acquire_duration_seconds",
+		"locker_store_errors_total",
+	} {
+		if got[name] != 1 {
+			t.Errorf("%s: %d metrics, want 1", name, got[name])
+		}
+	}
+}
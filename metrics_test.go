@@ -0,0 +1,74 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker/clocktest"
+)
+
+// lagMetrics records the watch lags it's given.
+type lagMetrics struct {
+	noMetrics
+
+	mu   sync.Mutex
+	lags []time.Duration
+}
+
+func (m *lagMetrics) WatchLag(name string, lag time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lags = append(m.lags, lag)
+}
+
+// burstStore pushes values to a watch in one go, then closes pushed.
+type burstStore struct {
+	MemoryStore
+	values []string
+	pushed chan struct{}
+}
+
+func (s *burstStore) Watch(ctx context.Context, name string, valueChanges chan<- string) error {
+	for _, v := range s.values {
+		valueChanges <- v
+	}
+	close(s.pushed)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestWatchLag(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	store := &burstStore{values: []string{"a", "b", "c"}, pushed: make(chan struct{})}
+	metrics := &lagMetrics{}
+	client := Client{Store: store, Clock: clock, Metrics: metrics}
+
+	valueChanges := make(chan string)
+	quit := make(chan bool)
+	defer close(quit)
+	go client.Watch(name, valueChanges, quit)
+
+	// c came after a and b were noted, the receiver takes its time
+	select {
+	case <-store.pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the changes to be taken from the store before they're received")
+	}
+	clock.Advance(2 * time.Second)
+	for _, want := range store.values {
+		if v := <-valueChanges; v != want {
+			t.Fatalf("expected %q, got %q", want, v)
+		}
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	for i, lag := range metrics.lags[:2] {
+		if lag != 2*time.Second {
+			t.Errorf("expected change %d to lag from when it came from the store, got %s", i, lag)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package locker
+
+import (
+	"context"
+	"time"
+)
+
+// QuorumStore is a backing store for Locker which holds a lock across
+// several independent Stores, in the style of Redlock. A lock is only
+// held when a majority of the member stores accepted it within its
+// validity window, so losing a minority of the members, or one of them
+// splitting its brain, doesn't hand the lock to two owners.
+//
+//     store := &locker.QuorumStore{
+//         Stores: []locker.Store{east.Store, west.Store, central.Store},
+//         TTL:    5,
+//     }
+//
+// The members should be independent deployments, and use the same TTL
+// as the QuorumStore.
+type QuorumStore struct {
+	// Stores are the member stores. Use an odd number of them.
+	Stores []Store
+
+	// TTL is the time-to-live of the locks in the member stores in
+	// seconds. Default: 5s.
+	TTL int64
+
+	// Drift is the share of the TTL set aside for clock drift between the
+	// members. A lock has to be accepted by a majority before
+	// TTL * (1 - Drift) has passed. Default: 0.01.
+	Drift float64
+
+	// Log, if set, receives a debug event for every operation, with its
+	// key and latency, and a warning when a lock the members didn't
+	// agree on couldn't be rolled back.
+	Log Logger
+}
+
+type quorumResult struct {
+	value string
+	locks map[string]string
+	held  bool
+	err   error
+
+	// late is where the result of a member which didn't answer within
+	// the validity window still comes.
+	late <-chan quorumResult
+}
+
+// Get returns the value a majority of the member stores agree on.
+// LockNotFound will be returned if a majority doesn't hold the lock.
+func (s *QuorumStore) Get(ctx context.Context, name string) (_ string, err error) {
+	defer s.log("Get", name, time.Now(), &err)
+
+	results := s.each(ctx, func(ctx context.Context, store Store) quorumResult {
+		v, err := store.Get(ctx, name)
+		return quorumResult{value: v, err: err}
+	})
+
+	values := make(map[string]int)
+	missing := 0
+	var errs []error
+	for _, r := range results {
+		switch r.err.(type) {
+		case nil:
+			values[r.value]++
+		case LockNotFound:
+			missing++
+		default:
+			errs = append(errs, r.err)
+		}
+	}
+
+	for v, n := range values {
+		if n >= s.quorum() {
+			return v, nil
+		}
+	}
+	if missing >= s.quorum() {
+		return "", LockNotFound{name}
+	}
+	return "", QuorumNotReached{name, errs}
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is. The lock is offered to every member
+// store; if a majority doesn't accept it within the validity window, it's
+// released again on every member which may have acquired it, late ones
+// included. The members which held it already are left alone, so a
+// freshen failing on a few members doesn't release the lock on the
+// others.
+func (s *QuorumStore) AcquireOrFreshenLock(ctx context.Context, name, value string) (err error) {
+	defer s.log("AcquireOrFreshenLock", name, time.Now(), &err)
+
+	results := s.each(ctx, func(ctx context.Context, store Store) quorumResult {
+		v, err := store.Get(ctx, name)
+		held := err == nil && v == value
+		return quorumResult{held: held, err: store.AcquireOrFreshenLock(ctx, name, value)}
+	})
+
+	accepted := 0
+	denied := 0
+	var errs []error
+	for _, r := range results {
+		switch r.err.(type) {
+		case nil:
+			accepted++
+		case LockDenied:
+			denied++
+		default:
+			errs = append(errs, r.err)
+		}
+	}
+
+	if accepted >= s.quorum() {
+		return nil
+	}
+
+	// we didn't get it, don't leave a minority of the members locked; it
+	// may have expired there meanwhile, and been taken by somebody who did
+	// get a majority, so it's only released if it's still held with value
+	s.rollback("AcquireOrFreshenLock", name, results, func(ctx context.Context, store Store) error {
+		return compareAndDelete(ctx, store, name, value)
+	})
+
+	if denied > 0 {
+		return LockDenied{name}
+	}
+	return QuorumNotReached{name, errs}
+}
+
+// Delete releases the named lock on all the member stores, regardless of
+// who holds it. It only fails if a majority of the members couldn't be
+// reached.
+func (s *QuorumStore) Delete(ctx context.Context, name string) (err error) {
+	defer s.log("Delete", name, time.Now(), &err)
+
+	results := s.each(ctx, func(ctx context.Context, store Store) quorumResult {
+		return quorumResult{err: store.Delete(ctx, name)}
+	})
+
+	deleted := 0
+	var errs []error
+	for _, r := range results {
+		if r.err == nil {
+			deleted++
+		} else {
+			errs = append(errs, r.err)
+		}
+	}
+
+	if deleted >= s.quorum() {
+		return nil
+	}
+	return QuorumNotReached{name, errs}
+}
+
+// CompareAndDelete releases the named lock on all the member stores if
+// it's held with value; atomically on members which are
+// CompareAndDeleters. LockNotFound is returned if a majority doesn't
+// hold it, and LockDenied if it's held with another value and a majority
+// didn't release it.
+func (s *QuorumStore) CompareAndDelete(ctx context.Context, name, value string) (err error) {
+	defer s.log("CompareAndDelete", name, time.Now(), &err)
+
+	results := s.each(ctx, func(ctx context.Context, store Store) quorumResult {
+		return quorumResult{err: compareAndDelete(ctx, store, name, value)}
+	})
+
+	deleted := 0
+	denied := 0
+	missing := 0
+	var errs []error
+	for _, r := range results {
+		switch r.err.(type) {
+		case nil:
+			deleted++
+		case LockDenied:
+			denied++
+		case LockNotFound:
+			missing++
+		default:
+			errs = append(errs, r.err)
+		}
+	}
+
+	switch {
+	case deleted >= s.quorum():
+		return nil
+	case missing >= s.quorum():
+		return LockNotFound{name}
+	case deleted+denied+missing < s.quorum():
+		return QuorumNotReached{name, errs}
+	case denied > 0:
+		return LockDenied{name}
+	}
+	return nil
+}
+
+// Transfer gives the named lock held by from to to, with a fresh TTL,
+// on every member store. If a majority doesn't transfer it within the
+// validity window, it's handed back to from on every member which may
+// have done it. Every member has to be a Transferer.
+func (s *QuorumStore) Transfer(ctx context.Context, name, from, to string) (err error) {
+	defer s.log("Transfer", name, time.Now(), &err)
+
+	results := s.each(ctx, func(ctx context.Context, store Store) quorumResult {
+		transferer, ok := store.(Transferer)
+		if !ok {
+			return quorumResult{err: Unsupported{"Transfer"}}
+		}
+		return quorumResult{err: transferer.Transfer(ctx, name, from, to)}
+	})
+
+	transferred := 0
+	denied := 0
+	missing := 0
+	var errs []error
+	for _, r := range results {
+		switch r.err.(type) {
+		case nil:
+			transferred++
+		case LockDenied:
+			denied++
+		case LockNotFound:
+			missing++
+		default:
+			errs = append(errs, r.err)
+		}
+	}
+
+	if transferred >= s.quorum() {
+		return nil
+	}
+
+	// hand it back, don't leave a minority of the members transferred
+	s.rollback("Transfer", name, results, func(ctx context.Context, store Store) error {
+		return store.(Transferer).Transfer(ctx, name, to, from)
+	})
+
+	switch {
+	case missing >= s.quorum():
+		return LockNotFound{name}
+	case denied > 0:
+		return LockDenied{name}
+	}
+	return QuorumNotReached{name, errs}
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// the value a majority of the member stores agree on. Every member has
+// to be a Lister.
+func (s *QuorumStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	results := s.each(ctx, func(ctx context.Context, store Store) quorumResult {
+		lister, ok := store.(Lister)
+		if !ok {
+			return quorumResult{err: Unsupported{"List"}}
+		}
+		locks, err := lister.List(ctx, prefix)
+		return quorumResult{locks: locks, err: err}
+	})
+
+	type lock struct{ name, value string }
+	votes := make(map[lock]int)
+	answered := 0
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		answered++
+		for name, value := range r.locks {
+			votes[lock{name, value}]++
+		}
+	}
+	if answered < s.quorum() {
+		return nil, QuorumNotReached{prefix, errs}
+	}
+
+	locks := make(map[string]string)
+	for l, n := range votes {
+		if n >= s.quorum() {
+			locks[l.name] = l.value
+		}
+	}
+	return locks, nil
+}
+
+// rollback undoes op on every member which may have done it: all but
+// those which refused it or held the lock already, as an error doesn't
+// tell whether it was done before the member failed. A member which
+// didn't answer within the validity window is undone once it does, in
+// the background, so the undo can't overtake it.
+func (s *QuorumStore) rollback(op, name string, results []quorumResult, undo func(context.Context, Store) error) {
+	for i, r := range results {
+		if r.late != nil {
+			go func(i int, late <-chan quorumResult) {
+				if r := <-late; !r.refused() {
+					s.undo(op, name, i, undo)
+				}
+			}(i, r.late)
+			continue
+		}
+		if !r.refused() {
+			s.undo(op, name, i, undo)
+		}
+	}
+}
+
+// undo undoes op on the member i.
+func (s *QuorumStore) undo(op, name string, i int, undo func(context.Context, Store) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.validity())
+	defer cancel()
+
+	switch err := undo(ctx, s.Stores[i]); err.(type) {
+	case nil, LockNotFound, LockDenied:
+	default:
+		s.rollbackFailed(op, name, i, err)
+	}
+}
+
+// refused reports whether a member is known not to have done an
+// operation, or to have held the lock before it.
+func (r quorumResult) refused() bool {
+	switch r.err.(type) {
+	case LockDenied, LockNotFound, Unsupported:
+		return true
+	}
+	return r.held
+}
+
+// rollbackFailed warns that op couldn't be rolled back on the member i,
+// which is left with the lock until it expires.
+func (s *QuorumStore) rollbackFailed(op, name string, i int, err error) {
+	if s.Log == nil {
+		return
+	}
+	s.Log.Log(LevelWarn, "quorum rollback failed", Field{FieldOp, op}, Field{FieldKey, name}, Field{FieldMember, i}, Field{FieldError, err})
+}
+
+// each calls fn for every member store concurrently, and returns the
+// results in the order of Stores. A member which doesn't answer within
+// the validity window is counted as failed, so one hanging member can't
+// hold up the rest; its result still comes on late.
+func (s *QuorumStore) each(ctx context.Context, fn func(context.Context, Store) quorumResult) []quorumResult {
+	ctx, cancel := context.WithTimeout(ctx, s.validity())
+	defer cancel()
+
+	answers := make([]chan quorumResult, len(s.Stores))
+	done := make(chan int, len(s.Stores))
+	for i, store := range s.Stores {
+		answers[i] = make(chan quorumResult, 1)
+		go func(i int, store Store) {
+			answers[i] <- fn(ctx, store)
+			done <- i
+		}(i, store)
+	}
+
+	results := make([]quorumResult, len(s.Stores))
+	answered := make([]bool, len(s.Stores))
+	for range s.Stores {
+		select {
+		case i := <-done:
+			results[i] = <-answers[i]
+			answered[i] = true
+		case <-ctx.Done():
+			for i := range results {
+				if !answered[i] {
+					results[i] = quorumResult{err: context.DeadlineExceeded, late: answers[i]}
+				}
+			}
+			return results
+		}
+	}
+
+	return results
+}
+
+// quorum is the number of members which make up a majority.
+func (s *QuorumStore) quorum() int {
+	return len(s.Stores)/2 + 1
+}
+
+// validity is how long a lock offered to the members is valid for once
+// clock drift has been accounted for.
+func (s *QuorumStore) validity() time.Duration {
+	drift := s.Drift
+	if drift <= 0 {
+		drift = 0.01
+	}
+
+	ttl := time.Duration(s.lockTTL()) * time.Second
+	return ttl - time.Duration(float64(ttl)*drift)
+}
+
+// lockTTL gets the TTL of the locks being stored in the members.
+// Defaults to 5 seconds.
+func (s *QuorumStore) lockTTL() int64 {
+	if s.TTL <= 0 {
+		return 5
+	}
+
+	return s.TTL
+}
+
+// log sends the debug event of an operation, deferred by it, to the
+// Logger of the store.
+func (s *QuorumStore) log(op, name string, start time.Time, err *error) {
+	logStore(s.Log, "quorum", op, name, start, *err)
+}
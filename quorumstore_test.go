@@ -0,0 +1,257 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuorumStoreToleratesMinorityFailure(t *testing.T) {
+	down := &faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")}
+	store := &QuorumStore{Stores: []Store{&MemoryStore{}, &MemoryStore{}, down}}
+	ctx := context.Background()
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatalf("Expected lock with one member down, got %v", err)
+	}
+	if v, err := store.Get(ctx, name); err != nil || v != "a" {
+		t.Errorf("Expected value 'a', got %q, %v", v, err)
+	}
+	if err := store.Delete(ctx, name); err != nil {
+		t.Errorf("Expected Delete with one member down, got %v", err)
+	}
+}
+
+func TestQuorumStoreMajorityFailure(t *testing.T) {
+	up := &MemoryStore{}
+	store := &QuorumStore{Stores: []Store{
+		up,
+		&faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")},
+		&faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")},
+	}}
+	ctx := context.Background()
+
+	err := store.AcquireOrFreshenLock(ctx, name, "a")
+	if _, ok := err.(QuorumNotReached); !ok {
+		t.Fatalf("Expected QuorumNotReached, got %v", err)
+	}
+	if _, err := up.Get(ctx, name); err != (LockNotFound{name}) {
+		t.Errorf("Expected the minority lock to be released, got %v", err)
+	}
+}
+
+func TestQuorumStoreSplitVote(t *testing.T) {
+	// another client sneaked onto one of the members
+	taken := &MemoryStore{}
+	taken.AcquireOrFreshenLock(context.Background(), name, "b")
+
+	store := &QuorumStore{Stores: []Store{taken, &MemoryStore{}, &MemoryStore{}}}
+	ctx := context.Background()
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatalf("Expected lock with a majority free, got %v", err)
+	}
+
+	// the member it sneaked onto was locked already, and left alone
+	other := &QuorumStore{Stores: store.Stores}
+	if err := other.AcquireOrFreshenLock(ctx, name, "b"); err != (LockDenied{name}) {
+		t.Errorf("Expected LockDenied, got %v", err)
+	}
+	if v, _ := taken.Get(ctx, name); v != "b" {
+		t.Errorf("Expected the member held before to be left alone, got %q", v)
+	}
+
+	// a member it newly acquired is released
+	taken.Delete(ctx, name)
+	if err := other.AcquireOrFreshenLock(ctx, name, "b"); err != (LockDenied{name}) {
+		t.Errorf("Expected LockDenied, got %v", err)
+	}
+	if _, err := taken.Get(ctx, name); err != (LockNotFound{name}) {
+		t.Errorf("Expected the denied client's minority lock to be released, got %v", err)
+	}
+}
+
+func TestQuorumStoreValidityWindow(t *testing.T) {
+	members := []Store{
+		&faultyStore{Store: &MemoryStore{}, delay: 1200 * time.Millisecond},
+		&faultyStore{Store: &MemoryStore{}, delay: 1200 * time.Millisecond},
+		&MemoryStore{},
+	}
+	store := &QuorumStore{Stores: members, TTL: 1}
+	ctx := context.Background()
+
+	err := store.AcquireOrFreshenLock(ctx, name, "a")
+	if _, ok := err.(QuorumNotReached); !ok {
+		t.Fatalf("Expected QuorumNotReached outside the validity window, got %v", err)
+	}
+	if _, err := members[2].Get(ctx, name); err != (LockNotFound{name}) {
+		t.Errorf("Expected the minority lock to be released, got %v", err)
+	}
+}
+
+// lateStore grants locks after delay, whether the caller still waits or
+// not, as a member whose answer is lost on the way back.
+type lateStore struct {
+	MemoryStore
+	delay time.Duration
+}
+
+func (s *lateStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	time.Sleep(s.delay)
+	return s.MemoryStore.AcquireOrFreshenLock(context.Background(), name, value)
+}
+
+func TestQuorumStoreLateMemberRolledBack(t *testing.T) {
+	late := &lateStore{delay: 1200 * time.Millisecond}
+	store := &QuorumStore{Stores: []Store{
+		late,
+		&faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")},
+		&MemoryStore{},
+	}, TTL: 1}
+	ctx := context.Background()
+
+	if _, ok := store.AcquireOrFreshenLock(ctx, name, "a").(QuorumNotReached); !ok {
+		t.Fatal("Expected QuorumNotReached")
+	}
+
+	// the late member grants the lock after the cutoff, and is released
+	// once it does
+	time.Sleep(late.delay)
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := late.Get(ctx, name)
+		if err == (LockNotFound{name}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the late member's lock to be released, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQuorumStoreFailedFreshenKeepsLock(t *testing.T) {
+	up := &MemoryStore{}
+	flaky := []*faultyStore{{Store: &MemoryStore{}}, {Store: &MemoryStore{}}}
+	store := &QuorumStore{Stores: []Store{up, flaky[0], flaky[1]}}
+	ctx := context.Background()
+
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a majority is briefly unreachable while the owner freshens it
+	flaky[0].err, flaky[1].err = errors.New("unreachable"), errors.New("unreachable")
+	if _, ok := store.AcquireOrFreshenLock(ctx, name, "a").(QuorumNotReached); !ok {
+		t.Fatal("Expected QuorumNotReached")
+	}
+	if v, err := up.Get(ctx, name); err != nil || v != "a" {
+		t.Fatalf("Expected the freshened member to keep the lock, got %q, %v", v, err)
+	}
+
+	flaky[0].err, flaky[1].err = nil, nil
+	if err := store.AcquireOrFreshenLock(ctx, name, "a"); err != nil {
+		t.Errorf("Expected the owner to freshen it once back, got %v", err)
+	}
+}
+
+// retakenStore hands the locks it grants to "b" straight away, as though
+// they expired and somebody else took them.
+type retakenStore struct {
+	MemoryStore
+}
+
+func (s *retakenStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	if err := s.MemoryStore.AcquireOrFreshenLock(ctx, name, value); err != nil {
+		return err
+	}
+	s.MemoryStore.Delete(ctx, name)
+	return s.MemoryStore.AcquireOrFreshenLock(ctx, name, "b")
+}
+
+func TestQuorumStoreRollbackLeavesNewHolder(t *testing.T) {
+	retaken := &retakenStore{}
+	store := &QuorumStore{Stores: []Store{
+		retaken,
+		&faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")},
+		&faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")},
+	}}
+	ctx := context.Background()
+
+	if _, ok := store.AcquireOrFreshenLock(ctx, name, "a").(QuorumNotReached); !ok {
+		t.Fatal("Expected QuorumNotReached")
+	}
+	if v, err := retaken.Get(ctx, name); err != nil || v != "b" {
+		t.Errorf("Expected the new holder's lock to be left alone, got %q, %v", v, err)
+	}
+}
+
+// oneWayStore transfers locks, but fails to hand them back to "a".
+type oneWayStore struct {
+	MemoryStore
+}
+
+func (s *oneWayStore) Transfer(ctx context.Context, name, from, to string) error {
+	if to == "a" {
+		return errors.New("unreachable")
+	}
+	return s.MemoryStore.Transfer(ctx, name, from, to)
+}
+
+func TestQuorumStoreTransferRollbackFailure(t *testing.T) {
+	logs := &logRecorder{}
+	oneWay := &oneWayStore{}
+	store := &QuorumStore{Stores: []Store{
+		oneWay,
+		&faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")},
+		&faultyStore{Store: &MemoryStore{}, err: errors.New("unreachable")},
+	}, Log: logs}
+	ctx := context.Background()
+	oneWay.AcquireOrFreshenLock(ctx, name, "a")
+
+	if _, ok := store.Transfer(ctx, name, "a", "b").(QuorumNotReached); !ok {
+		t.Fatal("Expected QuorumNotReached")
+	}
+	ev := logs.find(t, "quorum rollback failed")
+	if ev.level != LevelWarn || ev.fields[FieldOp] != "Transfer" || ev.fields[FieldMember] != 0 || ev.fields[FieldError] == nil {
+		t.Errorf("rollback: %+v", ev)
+	}
+}
+
+// faultyStore wraps a Store, failing every call with err or delaying it.
+type faultyStore struct {
+	Store
+	err   error
+	delay time.Duration
+}
+
+func (s *faultyStore) fault(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.err
+}
+
+func (s *faultyStore) Get(ctx context.Context, name string) (string, error) {
+	if err := s.fault(ctx); err != nil {
+		return "", err
+	}
+	return s.Store.Get(ctx, name)
+}
+
+func (s *faultyStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	if err := s.fault(ctx); err != nil {
+		return err
+	}
+	return s.Store.AcquireOrFreshenLock(ctx, name, value)
+}
+
+func (s *faultyStore) Delete(ctx context.Context, name string) error {
+	if err := s.fault(ctx); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, name)
+}
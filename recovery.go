@@ -0,0 +1,85 @@
+package locker
+
+import "fmt"
+
+// Recovery is what the Handles of a Client do once their lock is lost,
+// taken by somebody else or expired during an outage of the Store.
+//
+//     client.Recovery = locker.ReacquireAndWait
+//
+//     lock, err := client.Acquire("scheduler", hostname)
+//     ...
+//     lock.OnOwnership(func(e locker.OwnershipEvent) {
+//         log.Printf("%s: %s", e.Name, e.State)
+//     })
+type Recovery int
+
+const (
+	// GiveUp leaves the lock lost. It's the default.
+	GiveUp Recovery = iota
+
+	// ReacquireIfFree acquires the lock again once the Store answers,
+	// unless somebody else has taken it meanwhile, in which case the
+	// lock is abandoned.
+	ReacquireIfFree
+
+	// ReacquireAndWait acquires the lock again once the Store answers,
+	// waiting for whoever has taken it meanwhile to let it go.
+	ReacquireAndWait
+)
+
+func (r Recovery) String() string {
+	switch r {
+	case GiveUp:
+		return "give up"
+	case ReacquireIfFree:
+		return "reacquire if free"
+	case ReacquireAndWait:
+		return "reacquire and wait"
+	}
+	return fmt.Sprintf("recovery(%d)", int(r))
+}
+
+// Ownership is a state of the ownership of a Handle's lock.
+type Ownership int
+
+const (
+	// OwnershipLost is entered when the lock is lost. The Handle tries
+	// to recover it, unless the Recovery is GiveUp.
+	OwnershipLost Ownership = iota + 1
+
+	// OwnershipRecovered is entered when a lost lock is acquired again.
+	OwnershipRecovered
+
+	// OwnershipAbandoned is entered when a lost lock is given up on as
+	// somebody else holds it. It's final.
+	OwnershipAbandoned
+
+	// OwnershipReleased is entered when the Handle is released. It's
+	// final.
+	OwnershipReleased
+)
+
+func (o Ownership) String() string {
+	switch o {
+	case OwnershipLost:
+		return "lost"
+	case OwnershipRecovered:
+		return "recovered"
+	case OwnershipAbandoned:
+		return "abandoned"
+	case OwnershipReleased:
+		return "released"
+	}
+	return fmt.Sprintf("ownership(%d)", int(o))
+}
+
+// OwnershipEvent reports that the ownership of a Handle's lock changed.
+type OwnershipEvent struct {
+	Name  string
+	State Ownership
+
+	// Err is why the lock was lost or abandoned: a LockLost or a
+	// LockDenied error.
+	Err error
+}
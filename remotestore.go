@@ -0,0 +1,421 @@
+package locker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemoteStore is a backing store for Locker which keeps its locks on a
+// locker server (lockerd), over its HTTP/JSON API. It lets Go services
+// use a Client without depending on etcd, or on whatever the server keeps
+// its locks in.
+//
+//     client := locker.NewRemote("http://lockerd:7979", 5, context.Background())
+//
+// The locks are leases on the server: they expire TTL seconds after they
+// were last acquired or freshened, like with any other Store. The store
+// remembers the locks it holds, its session, and freshens them with a
+// refresh, so a lock which was taken over in the meantime is reported as
+// LockDenied rather than silently acquired again. Requests failing to
+// reach the server are retried.
+type RemoteStore struct {
+	// URL is the address of the server, like "http://lockerd:7979".
+	URL string
+
+	// TTL is the time-to-live for the lock in seconds. Default: 5s.
+	TTL int64
+
+	// HTTPClient is used to talk to the server. Default:
+	// http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Retries is how many times a request which didn't reach the server,
+	// or which the server couldn't serve, is retried. Default: 3.
+	Retries int
+
+	// Backoff is the wait before the first retry, doubled for every
+	// following one. Default: 100ms.
+	Backoff time.Duration
+
+	// Log, if set, receives a debug event for every operation, with its
+	// key and latency.
+	Log Logger
+
+	mu   sync.Mutex
+	held map[string]string
+}
+
+// remoteRequest and remoteLock are the bodies of the server API, see
+// package server.
+type remoteRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	TTL   int64  `json:"ttl,omitempty"`
+}
+
+type remoteLock struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Locked bool   `json:"locked"`
+
+	// set instead when the server failed the request
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// remoteError is a request the server failed.
+type remoteError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e remoteError) Error() string {
+	return fmt.Sprintf("locker server: %s (%s)", e.message, e.code)
+}
+
+// NewRemote creates a locker client using a locker server as a store.
+//
+//     client := locker.NewRemote("http://lockerd:7979", 5, context.Background())
+//
+func NewRemote(url string, ttl int64, ctx context.Context) Client {
+	return Client{
+		Store: &RemoteStore{
+			URL: url,
+			TTL: ttl,
+		},
+		ctx: ctx,
+	}
+}
+
+// Get returns the value of a lock. LockNotFound will be returned if a
+// lock with the name isn't held.
+func (s *RemoteStore) Get(ctx context.Context, name string) (_ string, err error) {
+	defer s.log("Get", name, time.Now(), &err)
+
+	var lock remoteLock
+	if err := s.do(ctx, http.MethodGet, "/v1/inspect?name="+url.QueryEscape(name), nil, &lock); err != nil {
+		return "", s.lockError(name, err)
+	}
+	if !lock.Locked {
+		return "", LockNotFound{name}
+	}
+	return lock.Value, nil
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is. A lock held by the session is
+// refreshed; should the server have forgotten it, after it was
+// restarted, it is acquired again.
+func (s *RemoteStore) AcquireOrFreshenLock(ctx context.Context, name, value string) (err error) {
+	defer s.log("AcquireOrFreshenLock", name, time.Now(), &err)
+
+	req := remoteRequest{Name: name, Value: value, TTL: s.lockTTL()}
+
+	if s.holds(name, value) {
+		err = s.do(ctx, http.MethodPost, "/v1/refresh", req, nil)
+		if _, notFound := s.lockError(name, err).(LockNotFound); notFound {
+			err = s.do(ctx, http.MethodPost, "/v1/acquire", req, nil)
+		}
+	} else {
+		err = s.do(ctx, http.MethodPost, "/v1/acquire", req, nil)
+	}
+
+	err = s.lockError(name, err)
+	switch err.(type) {
+	case nil:
+		s.remember(name, value)
+	case LockDenied:
+		s.forget(name)
+	}
+	return err
+}
+
+// Delete releases the named lock, regardless of who holds it.
+func (s *RemoteStore) Delete(ctx context.Context, name string) (err error) {
+	defer s.log("Delete", name, time.Now(), &err)
+
+	value, err := s.Get(ctx, name)
+	switch err.(type) {
+	case nil:
+	case LockNotFound:
+		s.forget(name)
+		return nil
+	default:
+		return err
+	}
+
+	err = s.lockError(name, s.do(ctx, http.MethodPost, "/v1/release", remoteRequest{Name: name, Value: value}, nil))
+	switch err.(type) {
+	case nil, LockNotFound:
+		s.forget(name)
+		return nil
+	}
+	return err
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values.
+func (s *RemoteStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	var resp struct {
+		Locks []remoteLock `json:"locks"`
+	}
+	err := s.do(ctx, http.MethodGet, "/v1/list?prefix="+url.QueryEscape(prefix), nil, &resp)
+	if e, ok := err.(remoteError); ok && e.code == "unsupported" {
+		return nil, Unsupported{"List"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	locks := make(map[string]string, len(resp.Locks))
+	for _, lock := range resp.Locks {
+		locks[lock.Name] = lock.Value
+	}
+	return locks, nil
+}
+
+// Watch pushes the value of the named lock into valueChanges, and then
+// every change to it, until ctx is done. An empty string indicates the
+// lack of a lock. The server streams the changes; when the stream breaks
+// it's reconnected, and only the changes missed meanwhile are pushed.
+func (s *RemoteStore) Watch(ctx context.Context, name string, valueChanges chan<- string) error {
+	var lastValue string
+	first := true
+	push := func(v string) bool {
+		if v == lastValue && !first {
+			return true
+		}
+		first = false
+		lastValue = v
+		select {
+		case valueChanges <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	failures := 0
+	for {
+		resp, err := s.send(ctx, http.MethodGet, "/v1/watch?name="+url.QueryEscape(name), nil)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = readError(resp)
+			resp.Body.Close()
+			if !retryable(err) {
+				return s.lockError(name, err)
+			}
+		}
+
+		if err == nil {
+			lines := bufio.NewScanner(resp.Body)
+			for lines.Scan() {
+				var lock remoteLock
+				if err := json.Unmarshal(lines.Bytes(), &lock); err != nil {
+					resp.Body.Close()
+					return err
+				}
+				if lock.Code != "" {
+					resp.Body.Close()
+					return s.lockError(name, remoteError{resp.StatusCode, lock.Code, lock.Error})
+				}
+				failures = 0
+				if !push(lock.Value) {
+					resp.Body.Close()
+					return nil
+				}
+			}
+			resp.Body.Close()
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		// the stream broke, reconnect unless the server keeps failing
+		if failures >= s.retries() {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if !s.sleep(ctx, failures) {
+			return nil
+		}
+		failures++
+	}
+}
+
+// Close releases the locks held by the session.
+func (s *RemoteStore) Close() error {
+	s.mu.Lock()
+	held := s.held
+	s.held = nil
+	s.mu.Unlock()
+
+	var firstErr error
+	for name, value := range held {
+		err := s.do(context.Background(), http.MethodPost, "/v1/release", remoteRequest{Name: name, Value: value}, nil)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// do sends a request to the server, retrying it while it fails to get
+// through, and decodes the response into out.
+func (s *RemoteStore) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := s.send(ctx, method, path, payload)
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				if out != nil {
+					err = json.NewDecoder(resp.Body).Decode(out)
+				}
+				resp.Body.Close()
+				return err
+			}
+			err = readError(resp)
+			resp.Body.Close()
+		}
+
+		if !retryable(err) || attempt >= s.retries() || !s.sleep(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+func (s *RemoteStore) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(s.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return s.httpClient().Do(req.WithContext(ctx))
+}
+
+// sleep waits out the backoff before retry attempt+1, and tells whether
+// ctx is still live afterwards.
+func (s *RemoteStore) sleep(ctx context.Context, attempt int) bool {
+	backoff := s.Backoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+
+	select {
+	case <-time.After(backoff << uint(attempt)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readError decodes the error a server answered with.
+func readError(resp *http.Response) error {
+	var lock remoteLock
+	if err := json.NewDecoder(resp.Body).Decode(&lock); err != nil || lock.Code == "" {
+		return remoteError{resp.StatusCode, "", resp.Status}
+	}
+	return remoteError{resp.StatusCode, lock.Code, lock.Error}
+}
+
+// retryable tells whether a request failing with err may go through on
+// another try: it didn't reach the server, or the server's Store failed.
+func retryable(err error) bool {
+	e, ok := err.(remoteError)
+	if !ok {
+		// the context is done, there's no point trying again
+		return err != context.Canceled && err != context.DeadlineExceeded
+	}
+	return e.status >= 500 && e.status != http.StatusNotImplemented
+}
+
+// lockError maps the errors of the server onto the errors of locker.
+func (s *RemoteStore) lockError(name string, err error) error {
+	e, ok := err.(remoteError)
+	if !ok {
+		return err
+	}
+	switch e.code {
+	case "denied":
+		return LockDenied{name}
+	case "not_found":
+		return LockNotFound{name}
+	}
+	return err
+}
+
+func (s *RemoteStore) holds(name, value string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.held[name]
+	return ok && v == value
+}
+
+func (s *RemoteStore) remember(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held == nil {
+		s.held = make(map[string]string)
+	}
+	s.held[name] = value
+}
+
+func (s *RemoteStore) forget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.held, name)
+}
+
+func (s *RemoteStore) httpClient() *http.Client {
+	if s.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return s.HTTPClient
+}
+
+func (s *RemoteStore) retries() int {
+	if s.Retries <= 0 {
+		return 3
+	}
+	return s.Retries
+}
+
+// lockTTL gets the TTL of the locks being stored on the server. Defaults
+// to 5 seconds.
+func (s *RemoteStore) lockTTL() int64 {
+	if s.TTL <= 0 {
+		return 5
+	}
+
+	return s.TTL
+}
+
+// log sends the debug event of an operation, deferred by it, to the
+// Logger of the store.
+func (s *RemoteStore) log(op, name string, start time.Time, err *error) {
+	logStore(s.Log, "remote", op, name, start, *err)
+}
@@ -0,0 +1,122 @@
+package locker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/server"
+)
+
+func TestRemoteStoreSession(t *testing.T) {
+	backing := &locker.MemoryStore{TTL: 10}
+	srv := server.New(backing)
+	var handler atomic.Value
+	handler.Store(srv)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.Load().(http.Handler).ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	store := &locker.RemoteStore{URL: ts.URL, TTL: 10}
+	if err := store.AcquireOrFreshenLock(ctx, "job", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server restarts and forgets its leases, the lock is still in
+	// the backing store
+	srv.Close()
+	restarted := server.New(backing)
+	defer restarted.Close()
+	handler.Store(restarted)
+
+	if err := store.AcquireOrFreshenLock(ctx, "job", "a"); err != nil {
+		t.Errorf("Expected the session to refresh its lock after a restart, got %v", err)
+	}
+
+	// the lock expired and was taken by somebody else meanwhile
+	backing.Delete(ctx, "job")
+	backing.AcquireOrFreshenLock(ctx, "job", "b")
+	if _, ok := store.AcquireOrFreshenLock(ctx, "job", "a").(locker.LockDenied); !ok {
+		t.Error("Expected a lock taken over to be denied")
+	}
+
+	if err := store.AcquireOrFreshenLock(ctx, "other", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backing.Get(ctx, "other"); err == nil {
+		t.Error("Expected Close to release the locks of the session")
+	}
+	if v, _ := backing.Get(ctx, "job"); v != "b" {
+		t.Errorf("Expected Close to leave locks of others alone, got %q", v)
+	}
+}
+
+func TestRemoteStoreRetries(t *testing.T) {
+	srv := server.New(&locker.MemoryStore{})
+	defer srv.Close()
+
+	var failures int32 = 2
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	store := &locker.RemoteStore{URL: ts.URL, Backoff: time.Millisecond}
+	if err := store.AcquireOrFreshenLock(context.Background(), "job", "a"); err != nil {
+		t.Fatalf("Expected the request to be retried, got %v", err)
+	}
+
+	atomic.StoreInt32(&failures, 10)
+	if err := store.AcquireOrFreshenLock(context.Background(), "job", "a"); err == nil {
+		t.Error("Expected an error once the retries ran out")
+	}
+}
+
+func TestRemoteStoreWatchReconnects(t *testing.T) {
+	backing := &locker.MemoryStore{}
+	srv := server.New(backing)
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	store := &locker.RemoteStore{URL: ts.URL, Backoff: time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan string)
+	go store.Watch(ctx, "job", changes)
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case v := <-changes:
+			if v != want {
+				t.Fatalf("Expected %q, got %q", want, v)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for %q", want)
+		}
+	}
+
+	expect("")
+	backing.AcquireOrFreshenLock(ctx, "job", "a")
+	expect("a")
+
+	// break the stream, the change made meanwhile still comes through
+	ts.CloseClientConnections()
+	backing.Delete(ctx, "job")
+	expect("")
+}
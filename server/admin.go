@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// AdminRequest is the body of the admin operations.
+type AdminRequest struct {
+	Name   string `json:"name,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Holder string `json:"holder,omitempty"`
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// AdminResponse lists the changes an admin operation made.
+type AdminResponse struct {
+	Actions []locker.AdminAction `json:"actions"`
+}
+
+func (s *Server) forceRelease(ctx context.Context, req AdminRequest) ([]locker.AdminAction, error) {
+	if req.Name == "" {
+		return nil, Error{"name is required", CodeBadRequest}
+	}
+	action, err := s.adminFor(req).ForceRelease(ctx, req.Name, req.Holder, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return []locker.AdminAction{action}, nil
+}
+
+func (s *Server) transfer(ctx context.Context, req AdminRequest) ([]locker.AdminAction, error) {
+	if req.Name == "" || req.To == "" {
+		return nil, Error{"name and to are required", CodeBadRequest}
+	}
+	action, err := s.adminFor(req).Transfer(ctx, req.Name, req.Holder, req.To, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return []locker.AdminAction{action}, nil
+}
+
+func (s *Server) revoke(ctx context.Context, req AdminRequest) ([]locker.AdminAction, error) {
+	if req.Prefix == "" {
+		return nil, Error{"prefix is required", CodeBadRequest}
+	}
+	return s.adminFor(req).Revoke(ctx, req.Prefix, req.Reason)
+}
+
+func (s *Server) adminFor(req AdminRequest) locker.Admin {
+	return locker.Admin{Store: s.Store, Audit: s.Audit, DryRun: req.DryRun}
+}
+
+// admin adapts an admin operation to a handler taking a JSON
+// AdminRequest. The leases of the locks it changed are dropped, so the
+// server doesn't keep them alive for their former holders.
+func (s *Server) admin(op func(context.Context, AdminRequest) ([]locker.AdminAction, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.Admin {
+			writeError(w, Error{"admin operations are turned off", CodeForbidden})
+			return
+		}
+
+		var req AdminRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, Error{"invalid request: " + err.Error(), CodeBadRequest})
+			return
+		}
+
+		actions, err := op(r.Context(), req)
+		if !req.DryRun {
+			s.mu.Lock()
+			for _, action := range actions {
+				if l, ok := s.leases[action.Name]; ok && l.value == action.Holder {
+					delete(s.leases, action.Name)
+				}
+			}
+			s.mu.Unlock()
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if actions == nil {
+			actions = []locker.AdminAction{}
+		}
+		writeJSON(w, http.StatusOK, AdminResponse{actions})
+	}
+}
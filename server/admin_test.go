@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+func postAdmin(t *testing.T, ts *httptest.Server, op string, req AdminRequest) (int, AdminResponse, Error) {
+	t.Helper()
+
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(ts.URL+"/v1/admin/"+op, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var ar AdminResponse
+	var e Error
+	if resp.StatusCode == http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(&ar)
+	} else {
+		json.NewDecoder(resp.Body).Decode(&e)
+	}
+	return resp.StatusCode, ar, e
+}
+
+func TestAdminOff(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	if status, _, e := postAdmin(t, ts, "release", AdminRequest{Name: "job", Reason: "x"}); status != http.StatusForbidden || e.Code != CodeForbidden {
+		t.Errorf("Expected admin operations to be off by default, got %d %+v", status, e)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	store := &locker.MemoryStore{}
+	var log bytes.Buffer
+	srv := New(store)
+	srv.Admin = true
+	srv.Audit = &locker.JSONAuditor{W: &log}
+	ts := httptest.NewServer(srv)
+	defer func() {
+		ts.Close()
+		srv.Close()
+	}()
+	ctx := context.Background()
+
+	post(t, ts, "acquire", Request{Name: "job", Value: "a", TTL: 10})
+	if status, _, e := postAdmin(t, ts, "release", AdminRequest{Name: "job"}); status != http.StatusBadRequest {
+		t.Errorf("Expected a reason to be required, got %d %+v", status, e)
+	}
+
+	status, resp, _ := postAdmin(t, ts, "transfer", AdminRequest{Name: "job", Holder: "a", To: "b", Reason: "failover"})
+	if status != http.StatusOK || len(resp.Actions) != 1 || resp.Actions[0].Holder != "a" {
+		t.Fatalf("transfer: %d %+v", status, resp)
+	}
+	if v, _ := store.Get(ctx, "job"); v != "b" {
+		t.Errorf("Expected the lock to be transferred, got %q", v)
+	}
+	if srv.holds("job", "a") {
+		t.Error("Expected the lease of the former holder to be dropped")
+	}
+	// the new holder takes over the lease with a refresh
+	if status, _, _ := post(t, ts, "refresh", Request{Name: "job", Value: "b"}); status != http.StatusOK {
+		t.Errorf("Expected the new holder to refresh, got %d", status)
+	}
+
+	post(t, ts, "acquire", Request{Name: "jobs/x", Value: "1"})
+	post(t, ts, "acquire", Request{Name: "jobs/y", Value: "2"})
+	status, resp, _ = postAdmin(t, ts, "revoke", AdminRequest{Prefix: "jobs/", Reason: "deploy", DryRun: true})
+	if status != http.StatusOK || len(resp.Actions) != 2 || !resp.Actions[0].DryRun {
+		t.Errorf("dry run revoke: %d %+v", status, resp)
+	}
+	if _, err := store.Get(ctx, "jobs/x"); err != nil {
+		t.Error("Expected a dry run to leave the locks alone")
+	}
+	if status, resp, _ := postAdmin(t, ts, "revoke", AdminRequest{Prefix: "jobs/", Reason: "deploy"}); status != http.StatusOK || len(resp.Actions) != 2 {
+		t.Errorf("revoke: %d %+v", status, resp)
+	}
+	if locks, _ := store.List(ctx, "jobs/"); len(locks) != 0 {
+		t.Errorf("Expected the locks to be revoked, left %v", locks)
+	}
+
+	if status, _, _ := postAdmin(t, ts, "release", AdminRequest{Name: "job", Holder: "a", Reason: "x"}); status != http.StatusConflict {
+		t.Errorf("Expected release of a lock with another holder to be denied, got %d", status)
+	}
+	if status, _, _ := postAdmin(t, ts, "release", AdminRequest{Name: "job", Reason: "wedged"}); status != http.StatusOK {
+		t.Errorf("release: %d", status)
+	}
+	if n := bytes.Count(log.Bytes(), []byte("\n")); n != 4 {
+		t.Errorf("Expected 4 recorded changes, got %d:\n%s", n, log.String())
+	}
+}
@@ -0,0 +1,380 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// eventBuffer is how many events are kept for subscribers resuming after
+// a reconnect.
+const eventBuffer = 1024
+
+// Event is a change to a lock, as streamed by /v1/events and /v1/ws.
+// IDs grow with every change the server sees, so a subscriber can resume
+// from the last one it got.
+type Event struct {
+	ID uint64 `json:"id"`
+	Lock
+}
+
+// topic is what a subscriber follows: a single lock or every lock under
+// a prefix.
+type topic struct {
+	name   string
+	prefix bool
+}
+
+func (t topic) matches(name string) bool {
+	if t.prefix {
+		return strings.HasPrefix(name, t.name)
+	}
+	return name == t.name
+}
+
+// hub turns the changes the server sees into numbered events, and fans
+// them out to the subscribers. Locks are watched for as long as somebody
+// subscribes to them, and every watch publishes into the same hub, so
+// overlapping subscriptions see the same events with the same IDs.
+type hub struct {
+	s *Server
+
+	mu       sync.Mutex
+	closed   bool
+	nextID   uint64
+	events   []Event
+	latest   map[string]Event
+	subs     map[chan Event]topic
+	watchers map[topic]*watcher
+}
+
+// watcher is a watch on a topic, shared by its subscribers.
+type watcher struct {
+	refs   int
+	cancel context.CancelFunc
+}
+
+func newHub(s *Server) *hub {
+	return &hub{
+		s:        s,
+		latest:   make(map[string]Event),
+		subs:     make(map[chan Event]topic),
+		watchers: make(map[topic]*watcher),
+	}
+}
+
+// publish records the value of a lock, and makes an event of it if it
+// changed. An empty value is the lack of a lock.
+func (h *hub) publish(name, value string) {
+	lock := Lock{Name: name}
+	if value != "" {
+		lock = h.s.describe(name, value)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if last, ok := h.latest[name]; ok && last.Value == value {
+		return
+	}
+
+	h.nextID++
+	ev := Event{ID: h.nextID, Lock: lock}
+	h.latest[name] = ev
+	h.events = append(h.events, ev)
+	h.trim()
+
+	for sub, t := range h.subs {
+		if !t.matches(name) {
+			continue
+		}
+		select {
+		case sub <- ev:
+		default:
+			// too slow, drop it; it can resume from its last event
+			delete(h.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+// trim keeps the buffer at eventBuffer events. Released locks are
+// forgotten once their release leaves the buffer.
+func (h *hub) trim() {
+	if len(h.events) <= eventBuffer {
+		return
+	}
+	drop := len(h.events) - eventBuffer
+	for _, ev := range h.events[:drop] {
+		if last := h.latest[ev.Name]; last.ID == ev.ID && !ev.Locked {
+			delete(h.latest, ev.Name)
+		}
+	}
+	h.events = append(h.events[:0:0], h.events[drop:]...)
+}
+
+// subscribe starts following t. Events after lastID are replayed first
+// when they're still buffered; otherwise, or without a lastID, the
+// current state of the matching locks is. So is it for a lastID past the
+// last event, which was seen before the server restarted.
+func (h *hub) subscribe(t topic, lastID uint64) (chan Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, Error{"server is closing", CodeStore}
+	}
+
+	var replay []Event
+	if lastID > 0 && len(h.events) > 0 && lastID+1 >= h.events[0].ID && lastID <= h.nextID {
+		for _, ev := range h.events {
+			if ev.ID > lastID && t.matches(ev.Name) {
+				replay = append(replay, ev)
+			}
+		}
+	} else {
+		for name, ev := range h.latest {
+			if t.matches(name) && (ev.Locked || !t.prefix) {
+				replay = append(replay, ev)
+			}
+		}
+		sort.Slice(replay, func(i, j int) bool { return replay[i].ID < replay[j].ID })
+	}
+
+	sub := make(chan Event, len(replay)+64)
+	for _, ev := range replay {
+		sub <- ev
+	}
+	h.subs[sub] = t
+
+	w, ok := h.watchers[t]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		w = &watcher{cancel: cancel}
+		h.watchers[t] = w
+		go h.watch(ctx, t)
+	}
+	w.refs++
+
+	return sub, nil
+}
+
+func (h *hub) unsubscribe(t topic, sub chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub)
+	}
+	if w, ok := h.watchers[t]; ok {
+		w.refs--
+		if w.refs == 0 {
+			w.cancel()
+			delete(h.watchers, t)
+		}
+	}
+}
+
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		close(sub)
+	}
+	h.subs = make(map[chan Event]topic)
+	for t, w := range h.watchers {
+		w.cancel()
+		delete(h.watchers, t)
+	}
+}
+
+// watch publishes the changes to the locks of t until ctx is done.
+func (h *hub) watch(ctx context.Context, t topic) {
+	if !t.prefix {
+		if w, ok := h.s.Store.(locker.Watcher); ok {
+			changes := make(chan string)
+			go func() {
+				for {
+					select {
+					case v := <-changes:
+						h.publish(t.name, v)
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			w.Watch(ctx, t.name, changes)
+			return
+		}
+	}
+
+	for {
+		h.poll(ctx, t)
+
+		select {
+		case <-time.After(h.s.watchInterval()):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll publishes the current state of the locks of t.
+func (h *hub) poll(ctx context.Context, t topic) {
+	if !t.prefix {
+		v, err := h.s.Store.Get(ctx, t.name)
+		switch err.(type) {
+		case nil, locker.LockNotFound:
+			h.publish(t.name, v)
+		}
+		return
+	}
+
+	lister, ok := h.s.Store.(locker.Lister)
+	if !ok {
+		return
+	}
+	locks, err := lister.List(ctx, t.name)
+	if err != nil {
+		return
+	}
+
+	// locks which were held and aren't anymore were released
+	h.mu.Lock()
+	for name, ev := range h.latest {
+		if _, ok := locks[name]; !ok && ev.Locked && t.matches(name) {
+			locks[name] = ""
+		}
+	}
+	h.mu.Unlock()
+
+	for name, value := range locks {
+		h.publish(name, value)
+	}
+}
+
+// subscription reads the topic and the resume point of a request, and
+// subscribes to it.
+func (s *Server) subscription(r *http.Request) (topic, chan Event, error) {
+	q := r.URL.Query()
+	t := topic{name: q.Get("name")}
+	if prefix, ok := q["prefix"]; ok {
+		t = topic{name: prefix[0], prefix: true}
+		if _, ok := s.Store.(locker.Lister); !ok {
+			return t, nil, locker.Unsupported{}
+		}
+	} else if t.name == "" {
+		return t, nil, Error{"name or prefix is required", CodeBadRequest}
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = q.Get("last_event_id")
+	}
+	var lastID uint64
+	if last != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(last, 10, 64); err != nil {
+			return t, nil, Error{"invalid last event id", CodeBadRequest}
+		}
+	}
+
+	sub, err := s.hub.subscribe(t, lastID)
+	return t, sub, err
+}
+
+// events streams the changes to a lock, or to the locks under a prefix,
+// as Server-Sent Events.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	t, sub, err := s.subscription(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer s.hub.unsubscribe(t, sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev)
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: lock\ndata: %s\n\n", ev.ID, data); err != nil {
+				return
+			}
+			flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// websocket streams the changes to a lock, or to the locks under a
+// prefix, as WebSocket text messages, one Event each.
+func (s *Server) websocket(w http.ResponseWriter, r *http.Request) {
+	t, sub, err := s.subscription(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer s.hub.unsubscribe(t, sub)
+
+	ws, err := upgrade(w, r, s.Origins)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	for {
+		select {
+		case ev, ok := <-sub:
+			if !ok {
+				ws.writeClose(closeGoingAway)
+				return
+			}
+			data, _ := json.Marshal(ev)
+			if err := ws.writeText(data); err != nil {
+				return
+			}
+		case <-ws.closed:
+			return
+		}
+	}
+}
+
+func (s *Server) watchInterval() time.Duration {
+	if s.WatchInterval <= 0 {
+		return time.Second
+	}
+	return s.WatchInterval
+}
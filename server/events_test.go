@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+func newTestEventServer(t *testing.T) (*httptest.Server, *locker.MemoryStore) {
+	store := &locker.MemoryStore{}
+	srv := New(store)
+	srv.WatchInterval = 20 * time.Millisecond
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		srv.Close()
+		ts.Close()
+	})
+	return ts, store
+}
+
+// sse reads the events of a Server-Sent Events stream.
+type sse struct {
+	t     *testing.T
+	resp  *http.Response
+	lines *bufio.Scanner
+}
+
+func subscribeSSE(t *testing.T, ts *httptest.Server, query, lastID string) *sse {
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/events?"+query, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, ct)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return &sse{t, resp, bufio.NewScanner(resp.Body)}
+}
+
+// next returns the SSE id and the event of the next event.
+func (s *sse) next() (string, Event) {
+	s.t.Helper()
+
+	var id string
+	var ev Event
+	for s.lines.Scan() {
+		line := s.lines.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+				s.t.Fatal(err)
+			}
+		case line == "" && id != "":
+			return id, ev
+		}
+	}
+	s.t.Fatalf("stream ended: %v", s.lines.Err())
+	return "", ev
+}
+
+func TestEventsPrefix(t *testing.T) {
+	ts, store := newTestEventServer(t)
+	ctx := context.Background()
+
+	stream := subscribeSSE(t, ts, "prefix=jobs/", "")
+	store.AcquireOrFreshenLock(ctx, "jobs/a", "1")
+	store.AcquireOrFreshenLock(ctx, "other", "2")
+
+	acquiredID, ev := stream.next()
+	if ev.Name != "jobs/a" || !ev.Locked || ev.Value != "1" {
+		t.Fatalf("Expected jobs/a to be acquired, got %+v", ev)
+	}
+	store.Delete(ctx, "jobs/a")
+	releasedID, ev := stream.next()
+	if ev.Name != "jobs/a" || ev.Locked || releasedID <= acquiredID {
+		t.Fatalf("Expected jobs/a to be released, got %s %+v", releasedID, ev)
+	}
+
+	// resuming after the first event replays the second
+	resumed := subscribeSSE(t, ts, "prefix=jobs/", acquiredID)
+	if id, ev := resumed.next(); id != releasedID || ev.Locked {
+		t.Errorf("Expected the release to be replayed, got %s %+v", id, ev)
+	}
+}
+
+func TestEventsAfterRestart(t *testing.T) {
+	ts, store := newTestEventServer(t)
+	ctx := context.Background()
+
+	store.AcquireOrFreshenLock(ctx, "job", "a")
+	subscribeSSE(t, ts, "name=job", "").next()
+
+	// the id was handed out by the server before it restarted
+	stream := subscribeSSE(t, ts, "name=job", "1000")
+	if _, ev := stream.next(); !ev.Locked || ev.Value != "a" {
+		t.Errorf("Expected the current lock first, got %+v", ev)
+	}
+}
+
+func TestEventsName(t *testing.T) {
+	ts, store := newTestEventServer(t)
+	ctx := context.Background()
+
+	store.AcquireOrFreshenLock(ctx, "job", "a")
+	stream := subscribeSSE(t, ts, "name=job", "")
+	if _, ev := stream.next(); !ev.Locked || ev.Value != "a" {
+		t.Fatalf("Expected the current lock first, got %+v", ev)
+	}
+
+	// a second subscriber starts from the same state
+	second := subscribeSSE(t, ts, "name=job", "")
+	if _, ev := second.next(); ev.Value != "a" {
+		t.Errorf("Expected the current lock first, got %+v", ev)
+	}
+
+	store.Delete(ctx, "job")
+	if _, ev := stream.next(); ev.Locked {
+		t.Errorf("Expected the lock to be released, got %+v", ev)
+	}
+}
+
+func TestEventsBadRequest(t *testing.T) {
+	ts, _ := newTestEventServer(t)
+
+	if status, _, e := get(t, ts, "/v1/events"); status != http.StatusBadRequest || e.Code != CodeBadRequest {
+		t.Errorf("Expected a subscription without a topic to be rejected, got %d %+v", status, e)
+	}
+	if status, _, _ := get(t, ts, "/v1/events?name=job&last_event_id=x"); status != http.StatusBadRequest {
+		t.Errorf("Expected an invalid event id to be rejected, got %d", status)
+	}
+}
+
+func TestEventsWebSocket(t *testing.T) {
+	ts, store := newTestEventServer(t)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	io.WriteString(conn, "GET /v1/ws?name=job HTTP/1.1\r\n"+
+		"Host: locker\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the example handshake of RFC 6455
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected the connection to be upgraded, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	next := func() Event {
+		t.Helper()
+		var header [2]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			t.Fatal(err)
+		}
+		if header[0] != 0x81 {
+			t.Fatalf("Expected a final text frame, got %x", header[0])
+		}
+		n := int(header[1])
+		if n == 126 {
+			var ext [2]byte
+			io.ReadFull(r, ext[:])
+			n = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			t.Fatal(err)
+		}
+		var ev Event
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+
+	if ev := next(); ev.Locked || ev.Name != "job" {
+		t.Errorf("Expected the lock to start out free, got %+v", ev)
+	}
+	store.AcquireOrFreshenLock(context.Background(), "job", "a")
+	if ev := next(); !ev.Locked || ev.Value != "a" || ev.ID == 0 {
+		t.Errorf("Expected the lock to be held, got %+v", ev)
+	}
+
+	// a masked close frame from the client is answered
+	conn.Write([]byte{0x88, 0x82, 1, 2, 3, 4, 0x03 ^ 1, 0xE8 ^ 2})
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0x88 {
+		t.Errorf("Expected a close frame back, got %x %v", header, err)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	srv := New(&locker.MemoryStore{})
+	defer srv.Close()
+	srv.Origins = []string{"https://dashboard.example.com"}
+
+	for origin, want := range map[string]int{
+		"":                              http.StatusNotImplemented,
+		"http://locker":                 http.StatusNotImplemented,
+		"https://dashboard.example.com": http.StatusNotImplemented,
+		"https://evil.example.com":      http.StatusForbidden,
+	} {
+		r := httptest.NewRequest("GET", "http://locker/v1/ws?name=job", nil)
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+
+		// a recorder can't be hijacked, allowed origins get that far
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("Origin %q: expected %d, got %d", origin, want, w.Code)
+		}
+	}
+}
+
+func TestWebSocketStuckClient(t *testing.T) {
+	defer func(timeout time.Duration) { websocketWriteTimeout = timeout }(websocketWriteTimeout)
+	websocketWriteTimeout = 100 * time.Millisecond
+	ts, store := newTestEventServer(t)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /v1/ws?name=job HTTP/1.1\r\n"+
+		"Host: locker\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+
+	// the client takes none of the events, which outgrow the buffers
+	ctx := context.Background()
+	big := strings.Repeat("x", 1<<16)
+	for i := 0; i < 100; i++ {
+		store.Delete(ctx, "job")
+		store.AcquireOrFreshenLock(ctx, "job", fmt.Sprint(i, big))
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	// once given up on, the server closes the connection
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(ioutil.Discard, conn); err != nil {
+		t.Errorf("Expected the server to give up on the client, got %v", err)
+	}
+}
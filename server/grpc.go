@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/lockerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPC returns the server as a gRPC service. It shares its leases with
+// the HTTP API, so a lock taken through one can be inspected through the
+// other.
+//
+//	grpcServer := grpc.NewServer()
+//	lockerpb.RegisterLockerServer(grpcServer, srv.GRPC())
+func (s *Server) GRPC() lockerpb.LockerServer {
+	return grpcService{s}
+}
+
+type grpcService struct {
+	s *Server
+}
+
+func (g grpcService) Acquire(ctx context.Context, req *lockerpb.LockRequest) (*lockerpb.Lock, error) {
+	return g.lockOp(ctx, req, g.s.acquire)
+}
+
+func (g grpcService) Refresh(ctx context.Context, req *lockerpb.LockRequest) (*lockerpb.Lock, error) {
+	return g.lockOp(ctx, req, g.s.refresh)
+}
+
+func (g grpcService) Release(ctx context.Context, req *lockerpb.LockRequest) (*lockerpb.Lock, error) {
+	return g.lockOp(ctx, req, g.s.release)
+}
+
+func (g grpcService) Inspect(ctx context.Context, req *lockerpb.InspectRequest) (*lockerpb.Lock, error) {
+	lock, err := g.s.inspect(ctx, req.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProto(lock), nil
+}
+
+func (g grpcService) lockOp(ctx context.Context, req *lockerpb.LockRequest, op func(context.Context, Request) (Lock, error)) (*lockerpb.Lock, error) {
+	if req.Name == "" || req.Value == "" {
+		return nil, grpcError(Error{"name and value are required", CodeBadRequest})
+	}
+
+	lock, err := op(ctx, Request{Name: req.Name, Value: req.Value, TTL: req.Ttl})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProto(lock), nil
+}
+
+// session is the state of a Session stream: the locks it holds and the
+// locks it watches.
+type session struct {
+	s      *Server
+	ctx    context.Context
+	events chan *lockerpb.SessionEvent
+
+	held    map[string]Request
+	watches map[string]context.CancelFunc
+}
+
+// Session holds locks for as long as the stream is kept alive with
+// KEEPALIVE requests. A lock whose lease runs out, or which is taken
+// from the session in the Store, is reported LOST. Locks still held when
+// the stream ends are released.
+func (g grpcService) Session(stream lockerpb.Locker_SessionServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	sess := &session{
+		s:       g.s,
+		ctx:     ctx,
+		events:  make(chan *lockerpb.SessionEvent, 16),
+		held:    make(map[string]Request),
+		watches: make(map[string]context.CancelFunc),
+	}
+	defer sess.close()
+
+	// a stream can't be sent to concurrently, so events are sent from
+	// one goroutine and received from another
+	sendFailed := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case ev := <-sess.events:
+				if err := stream.Send(ev); err != nil {
+					sendFailed <- err
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	requests := make(chan *lockerpb.SessionRequest)
+	recvFailed := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvFailed <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	tick := time.NewTicker(reapInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case req := <-requests:
+			sess.handle(req)
+		case <-tick.C:
+			sess.checkLeases()
+		case err := <-recvFailed:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case err := <-sendFailed:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (sess *session) handle(req *lockerpb.SessionRequest) {
+	lockReq := Request{Name: req.Name, Value: req.Value, TTL: req.Ttl}
+
+	switch req.Kind {
+	case lockerpb.SessionRequest_KEEPALIVE:
+		for name, held := range sess.held {
+			if _, err := sess.s.refresh(sess.ctx, held); err != nil {
+				delete(sess.held, name)
+				sess.emit(lockerpb.SessionEvent_LOST, Lock{Name: name, Value: held.Value}, err)
+			}
+		}
+		sess.emit(lockerpb.SessionEvent_KEEPALIVE, Lock{}, nil)
+
+	case lockerpb.SessionRequest_HOLD:
+		if req.Name == "" || req.Value == "" {
+			sess.emit(lockerpb.SessionEvent_ERROR, Lock{Name: req.Name}, Error{"name and value are required", CodeBadRequest})
+			return
+		}
+		lock, err := sess.s.acquire(sess.ctx, lockReq)
+		switch err.(type) {
+		case nil:
+			sess.held[req.Name] = lockReq
+			sess.emit(lockerpb.SessionEvent_ACQUIRED, lock, nil)
+		case locker.LockDenied:
+			sess.emit(lockerpb.SessionEvent_DENIED, Lock{Name: req.Name}, err)
+		default:
+			sess.emit(lockerpb.SessionEvent_ERROR, Lock{Name: req.Name}, err)
+		}
+
+	case lockerpb.SessionRequest_RELEASE:
+		held, ok := sess.held[req.Name]
+		if !ok {
+			sess.emit(lockerpb.SessionEvent_ERROR, Lock{Name: req.Name}, Error{"lock isn't held by the session", CodeNotFound})
+			return
+		}
+		delete(sess.held, req.Name)
+		lock, err := sess.s.release(sess.ctx, held)
+		if err != nil {
+			sess.emit(lockerpb.SessionEvent_ERROR, Lock{Name: req.Name}, err)
+			return
+		}
+		sess.emit(lockerpb.SessionEvent_RELEASED, lock, nil)
+
+	case lockerpb.SessionRequest_WATCH:
+		if _, ok := sess.watches[req.Name]; ok || req.Name == "" {
+			sess.emit(lockerpb.SessionEvent_ERROR, Lock{Name: req.Name}, Error{"lock is watched already", CodeBadRequest})
+			return
+		}
+		ctx, cancel := context.WithCancel(sess.ctx)
+		sess.watches[req.Name] = cancel
+		sess.emit(lockerpb.SessionEvent_WATCHING, Lock{Name: req.Name}, nil)
+		go sess.watch(ctx, req.Name)
+
+	case lockerpb.SessionRequest_UNWATCH:
+		if cancel, ok := sess.watches[req.Name]; ok {
+			cancel()
+			delete(sess.watches, req.Name)
+		}
+		sess.emit(lockerpb.SessionEvent_UNWATCHED, Lock{Name: req.Name}, nil)
+	}
+}
+
+// checkLeases reports the locks the session has lost since the last
+// check.
+func (sess *session) checkLeases() {
+	for name, held := range sess.held {
+		if !sess.s.holds(name, held.Value) {
+			delete(sess.held, name)
+			sess.emit(lockerpb.SessionEvent_LOST, Lock{Name: name, Value: held.Value}, nil)
+		}
+	}
+}
+
+// watch pushes a CHANGED event whenever the named lock changes, until
+// ctx is done.
+func (sess *session) watch(ctx context.Context, name string) {
+	changes := make(chan string)
+	quit := make(chan bool)
+	failed := make(chan error, 1)
+	go func() {
+		client := locker.Client{Store: sess.s.Store}
+		failed <- client.Watch(name, changes, quit)
+	}()
+	defer close(quit)
+
+	for {
+		select {
+		case v := <-changes:
+			lock := Lock{Name: name}
+			if v != "" {
+				lock = sess.s.describe(name, v)
+			}
+			sess.emit(lockerpb.SessionEvent_CHANGED, lock, nil)
+		case err := <-failed:
+			if err != nil {
+				sess.emit(lockerpb.SessionEvent_ERROR, Lock{Name: name}, err)
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// close releases the locks the session still holds.
+func (sess *session) close() {
+	ctx := context.Background()
+	for _, held := range sess.held {
+		sess.s.release(ctx, held)
+	}
+}
+
+func (sess *session) emit(kind lockerpb.SessionEvent_Kind, lock Lock, err error) {
+	ev := &lockerpb.SessionEvent{Kind: kind, Lock: toProto(lock)}
+	if err != nil {
+		ev.Error = toError(err).Message
+	}
+
+	select {
+	case sess.events <- ev:
+	case <-sess.ctx.Done():
+	}
+}
+
+func toProto(lock Lock) *lockerpb.Lock {
+	pb := &lockerpb.Lock{Name: lock.Name, Value: lock.Value, Locked: lock.Locked}
+	if lock.Expires != nil {
+		pb.Expires = lock.Expires.UnixNano()
+	}
+	return pb
+}
+
+var grpcCodes = map[string]codes.Code{
+	CodeDenied:      codes.FailedPrecondition,
+	CodeNotFound:    codes.NotFound,
+	CodeBadRequest:  codes.InvalidArgument,
+	CodeUnsupported: codes.Unimplemented,
+	CodeStore:       codes.Unavailable,
+}
+
+// grpcError maps the errors of the Store onto gRPC statuses.
+func grpcError(err error) error {
+	e := toError(err)
+	return status.Error(grpcCodes[e.Code], e.Message)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/lockerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestGRPC(t *testing.T) (lockerpb.LockerClient, *locker.MemoryStore) {
+	store := &locker.MemoryStore{}
+	srv := New(store)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	lockerpb.RegisterLockerServer(grpcServer, srv.GRPC())
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+		srv.Close()
+	})
+	return lockerpb.NewLockerClient(conn), store
+}
+
+func TestGRPCUnary(t *testing.T) {
+	client, _ := newTestGRPC(t)
+	ctx := context.Background()
+
+	lock, err := client.Acquire(ctx, &lockerpb.LockRequest{Name: "job", Value: "a", Ttl: 10})
+	if err != nil || !lock.Locked || lock.Expires == 0 {
+		t.Fatalf("Acquire: %v %v", lock, err)
+	}
+	if _, err := client.Acquire(ctx, &lockerpb.LockRequest{Name: "job", Value: "b"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+	if lock, err := client.Inspect(ctx, &lockerpb.InspectRequest{Name: "job"}); err != nil || lock.Value != "a" {
+		t.Errorf("Inspect: %v %v", lock, err)
+	}
+	if _, err := client.Release(ctx, &lockerpb.LockRequest{Name: "job", Value: "a"}); err != nil {
+		t.Errorf("Release: %v", err)
+	}
+	if lock, err := client.Inspect(ctx, &lockerpb.InspectRequest{Name: "job"}); err != nil || lock.Locked {
+		t.Errorf("Expected the lock to be released, got %v %v", lock, err)
+	}
+	if _, err := client.Acquire(ctx, &lockerpb.LockRequest{Name: "job"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGRPCSession(t *testing.T) {
+	client, store := newTestGRPC(t)
+	ctx := context.Background()
+
+	first, err := client.Session(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.Session(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.Send(&lockerpb.SessionRequest{Kind: lockerpb.SessionRequest_HOLD, Name: "job", Value: "a", Ttl: 10})
+	expectEvent(t, first, lockerpb.SessionEvent_ACQUIRED)
+
+	second.Send(&lockerpb.SessionRequest{Kind: lockerpb.SessionRequest_WATCH, Name: "job"})
+	expectEvent(t, second, lockerpb.SessionEvent_WATCHING)
+	if ev := expectEvent(t, second, lockerpb.SessionEvent_CHANGED); ev.Lock.Value != "a" {
+		t.Errorf("Expected the watch to start with the held lock, got %v", ev)
+	}
+	second.Send(&lockerpb.SessionRequest{Kind: lockerpb.SessionRequest_HOLD, Name: "job", Value: "b"})
+	expectEvent(t, second, lockerpb.SessionEvent_DENIED)
+
+	first.Send(&lockerpb.SessionRequest{Kind: lockerpb.SessionRequest_KEEPALIVE})
+	expectEvent(t, first, lockerpb.SessionEvent_KEEPALIVE)
+
+	// ending the session releases its locks
+	first.CloseSend()
+	if ev := expectEvent(t, second, lockerpb.SessionEvent_CHANGED); ev.Lock.Locked {
+		t.Errorf("Expected the lock to be released with the session, got %v", ev)
+	}
+	if _, err := store.Get(ctx, "job"); err == nil {
+		t.Error("Expected the lock to be released in the store")
+	}
+}
+
+func TestGRPCSessionLost(t *testing.T) {
+	client, _ := newTestGRPC(t)
+
+	sess, err := client.Session(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess.Send(&lockerpb.SessionRequest{Kind: lockerpb.SessionRequest_HOLD, Name: "job", Value: "a", Ttl: 1})
+	expectEvent(t, sess, lockerpb.SessionEvent_ACQUIRED)
+
+	// no keepalives, the lease runs out
+	if ev := expectEvent(t, sess, lockerpb.SessionEvent_LOST); ev.Lock.Name != "job" {
+		t.Errorf("Expected job to be lost, got %v", ev)
+	}
+}
+
+func expectEvent(t *testing.T, stream lockerpb.Locker_SessionClient, kind lockerpb.SessionEvent_Kind) *lockerpb.SessionEvent {
+	t.Helper()
+
+	received := make(chan *lockerpb.SessionEvent, 1)
+	go func() {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Error(err)
+		}
+		received <- ev
+	}()
+
+	select {
+	case ev := <-received:
+		if ev == nil || ev.Kind != kind {
+			t.Fatalf("Expected a %s event, got %v", kind, ev)
+		}
+		return ev
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timeout waiting for a %s event", kind)
+	}
+	return nil
+}
@@ -0,0 +1,546 @@
+// Package server exposes locker over an HTTP/JSON API, for services which
+// can't link Go. It's what the lockerd daemon runs.
+//
+// All requests and responses are JSON. Lock operations are POSTed:
+//
+//	POST /v1/acquire  {"name": "job", "value": "host-a", "ttl": 10}
+//	POST /v1/refresh  {"name": "job", "value": "host-a", "ttl": 10}
+//	POST /v1/release  {"name": "job", "value": "host-a"}
+//
+// acquire takes a lock, or freshens it if the value already holds it.
+// refresh only freshens a lock the value holds. Both answer with the lock:
+//
+//	{"name": "job", "value": "host-a", "locked": true, "expires": "2018-03-01T10:00:10Z"}
+//
+// The server keeps a lease for every lock acquired through it: the lock
+// is kept alive in the Store for as long as the lease is, and released
+// once the lease hasn't been refreshed for ttl seconds. Clients which go
+// away lose their locks after their TTL.
+//
+// Locks are read with GETs:
+//
+//	GET /v1/inspect?name=job   the lock, "locked" is false if it isn't held
+//	GET /v1/list?prefix=jo     {"locks": [lock, ...]}
+//	GET /v1/watch?name=job     a stream of locks, one JSON document per line
+//
+// Browsers and scripts can follow a lock, or every lock under a prefix,
+// as Server-Sent Events or over a WebSocket:
+//
+//	GET /v1/events?name=job    text/event-stream of events
+//	GET /v1/events?prefix=jo
+//	GET /v1/ws?prefix=jo       a WebSocket text message per event
+//
+// Web pages of other origins than the server's can only open WebSockets
+// if they're in Origins.
+//
+// An event is a lock with an id, {"id": 42, "name": "job", ...}. IDs grow
+// with every change, and a subscriber reconnecting with the last one it
+// got, in the Last-Event-ID header or the last_event_id parameter, gets
+// the events it missed. Subscribers starting out, too far behind, or
+// with an id from before the server restarted, get the current state of
+// the locks first.
+//
+// With Admin set, operators can take locks away from wedged holders.
+// Every request needs a reason, which is recorded in Audit; with
+// "dry_run" the changes are only listed:
+//
+//	POST /v1/admin/release   {"name": "job", "holder": "host-a", "reason": "..."}
+//	POST /v1/admin/transfer  {"name": "job", "holder": "host-a", "to": "host-b", "reason": "..."}
+//	POST /v1/admin/revoke    {"prefix": "jobs/", "reason": "...", "dry_run": true}
+//
+// holder is optional; if it's set the lock is only changed while that
+// value holds it. All three answer with the changes, as recorded:
+//
+//	{"actions": [{"op": "transfer", "name": "job", "holder": "host-a", "to": "host-b", ...}]}
+//
+// Failures answer with an error and a code:
+//
+//	409 {"error": "...", "code": "denied"}       the lock is held by somebody else
+//	404 {"error": "...", "code": "not_found"}    the lock isn't held
+//	400 {"error": "...", "code": "bad_request"}
+//	403 {"error": "...", "code": "forbidden"}    admin operations are off
+//	501 {"error": "...", "code": "unsupported"}  the Store can't do it
+//	502 {"error": "...", "code": "store"}        the Store failed
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// Error codes of the API.
+const (
+	CodeDenied      = "denied"
+	CodeNotFound    = "not_found"
+	CodeBadRequest  = "bad_request"
+	CodeUnsupported = "unsupported"
+	CodeStore       = "store"
+	CodeForbidden   = "forbidden"
+)
+
+// reapInterval is how often leases are checked for expiry.
+const reapInterval = 250 * time.Millisecond
+
+// Server serves the locker HTTP API on top of a Store. Create it with
+// New and set its fields before it serves its first request. Close it to
+// stop its leases being tracked.
+type Server struct {
+	// Store is where the locks are kept.
+	Store locker.Store
+
+	// TTL is the lease time-to-live in seconds when a request doesn't
+	// ask for one. Default: 5s.
+	TTL int64
+
+	// MaxTTL caps the lease time-to-live a request can ask for, in
+	// seconds. Default: 300s.
+	MaxTTL int64
+
+	// Refresh is how often the locks of live leases are freshened in
+	// the Store. It has to be shorter than the TTL of the Store.
+	// Default: 1s.
+	Refresh time.Duration
+
+	// WatchInterval is how often the locks followed through /v1/events
+	// and /v1/ws are polled, when the Store can't push their changes.
+	// Default: 1s.
+	WatchInterval time.Duration
+
+	// Origins are the origins of the web pages, besides the server's
+	// own, which may open a WebSocket to /v1/ws, like
+	// "https://dashboard.example.com", or "*" for any page. Browsers
+	// open them with the credentials of whoever views the page.
+	Origins []string
+
+	// Admin turns on the admin operations. Keep the API away from
+	// anybody who shouldn't take locks away from their holders.
+	Admin bool
+
+	// Audit records the changes made by admin operations. It's required
+	// for them to make any.
+	Audit locker.Auditor
+
+	mux   *http.ServeMux
+	hub   *hub
+	start sync.Once
+	quit  chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	leases map[string]*lease
+}
+
+// lease is a lock the server holds on behalf of a client.
+type lease struct {
+	value     string
+	expires   time.Time
+	freshened time.Time
+}
+
+// Request is the body of the lock operations.
+type Request struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	TTL   int64  `json:"ttl,omitempty"`
+}
+
+// Lock is how the API describes a lock.
+type Lock struct {
+	Name    string     `json:"name"`
+	Value   string     `json:"value,omitempty"`
+	Locked  bool       `json:"locked"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Error is the body of a failed request.
+type Error struct {
+	Message string `json:"error"`
+	Code    string `json:"code"`
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// New creates a Server for store. Leases are tracked from the first
+// lock acquired through it on.
+func New(store locker.Store) *Server {
+	s := &Server{
+		Store:  store,
+		mux:    http.NewServeMux(),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+		leases: make(map[string]*lease),
+	}
+
+	s.mux.HandleFunc("/v1/acquire", s.post(s.acquire))
+	s.mux.HandleFunc("/v1/refresh", s.post(s.refresh))
+	s.mux.HandleFunc("/v1/release", s.post(s.release))
+	s.mux.HandleFunc("/v1/inspect", s.get(func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return s.inspect(ctx, r.URL.Query().Get("name"))
+	}))
+	s.mux.HandleFunc("/v1/list", s.get(s.list))
+	s.mux.HandleFunc("/v1/watch", s.watch)
+	s.mux.HandleFunc("/v1/events", s.events)
+	s.mux.HandleFunc("/v1/ws", s.websocket)
+	s.mux.HandleFunc("/v1/admin/release", s.admin(s.forceRelease))
+	s.mux.HandleFunc("/v1/admin/transfer", s.admin(s.transfer))
+	s.mux.HandleFunc("/v1/admin/revoke", s.admin(s.revoke))
+	s.hub = newHub(s)
+
+	return s
+}
+
+// ServeHTTP serves the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close stops tracking leases and ends the event streams. Locks held
+// through the server are left to expire in the Store.
+func (s *Server) Close() error {
+	s.hub.close()
+
+	// if nothing was ever leased there's no reaper to wait for
+	s.start.Do(func() { close(s.done) })
+	close(s.quit)
+	<-s.done
+	return nil
+}
+
+func (s *Server) acquire(ctx context.Context, req Request) (Lock, error) {
+	if err := s.Store.AcquireOrFreshenLock(ctx, req.Name, req.Value); err != nil {
+		return Lock{}, err
+	}
+	return s.lease(req), nil
+}
+
+func (s *Server) refresh(ctx context.Context, req Request) (Lock, error) {
+	if !s.holds(req.Name, req.Value) {
+		// not leased by us, maybe acquired before a restart
+		v, err := s.Store.Get(ctx, req.Name)
+		if err != nil {
+			return Lock{}, err
+		}
+		if v != req.Value {
+			return Lock{}, locker.NewLockDenied(req.Name)
+		}
+	}
+
+	if err := s.Store.AcquireOrFreshenLock(ctx, req.Name, req.Value); err != nil {
+		return Lock{}, err
+	}
+	return s.lease(req), nil
+}
+
+func (s *Server) release(ctx context.Context, req Request) (Lock, error) {
+	switch err := s.compareAndDelete(ctx, req.Name, req.Value); err.(type) {
+	case nil, locker.LockNotFound:
+	default:
+		return Lock{}, err
+	}
+
+	s.mu.Lock()
+	delete(s.leases, req.Name)
+	s.mu.Unlock()
+
+	return Lock{Name: req.Name}, nil
+}
+
+// compareAndDelete releases the named lock if value holds it, atomically
+// if the Store is a locker.CompareAndDeleter. Other Stores are asked for
+// the value first.
+func (s *Server) compareAndDelete(ctx context.Context, name, value string) error {
+	if deleter, ok := s.Store.(locker.CompareAndDeleter); ok {
+		return deleter.CompareAndDelete(ctx, name, value)
+	}
+
+	v, err := s.Store.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if v != value {
+		return locker.NewLockDenied(name)
+	}
+	return s.Store.Delete(ctx, name)
+}
+
+func (s *Server) inspect(ctx context.Context, name string) (Lock, error) {
+	if name == "" {
+		return Lock{}, Error{"name is required", CodeBadRequest}
+	}
+
+	v, err := s.Store.Get(ctx, name)
+	switch err.(type) {
+	case nil:
+		return s.describe(name, v), nil
+	case locker.LockNotFound:
+		return Lock{Name: name}, nil
+	}
+	return Lock{}, err
+}
+
+func (s *Server) list(ctx context.Context, r *http.Request) (interface{}, error) {
+	lister, ok := s.Store.(locker.Lister)
+	if !ok {
+		return nil, locker.Unsupported{}
+	}
+	locks, err := lister.List(ctx, r.URL.Query().Get("prefix"))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := struct {
+		Locks []Lock `json:"locks"`
+	}{[]Lock{}}
+	for name, value := range locks {
+		resp.Locks = append(resp.Locks, s.describe(name, value))
+	}
+	sort.Slice(resp.Locks, func(i, j int) bool { return resp.Locks[i].Name < resp.Locks[j].Name })
+	return resp, nil
+}
+
+// watch streams the lock as it changes, one JSON document per line,
+// until the client goes away.
+func (s *Server) watch(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, Error{"name is required", CodeBadRequest})
+		return
+	}
+
+	changes := make(chan string)
+	quit := make(chan bool)
+	failed := make(chan error, 1)
+	go func() {
+		client := locker.Client{Store: s.Store}
+		failed <- client.Watch(name, changes, quit)
+	}()
+	defer close(quit)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case v := <-changes:
+			lock := Lock{Name: name}
+			if v != "" {
+				lock = s.describe(name, v)
+			}
+			if err := enc.Encode(lock); err != nil {
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		case err := <-failed:
+			if err != nil {
+				enc.Encode(toError(err))
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// lease records the lease of a lock acquired or refreshed by req.
+func (s *Server) lease(req Request) Lock {
+	now := time.Now()
+	expires := now.Add(time.Duration(s.ttl(req.TTL)) * time.Second)
+
+	s.start.Do(func() { go s.reap() })
+
+	s.mu.Lock()
+	s.leases[req.Name] = &lease{value: req.Value, expires: expires, freshened: now}
+	s.mu.Unlock()
+
+	return Lock{Name: req.Name, Value: req.Value, Locked: true, Expires: &expires}
+}
+
+// holds tells whether value holds a lease on the named lock.
+func (s *Server) holds(name, value string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[name]
+	return ok && l.value == value
+}
+
+// describe returns a held lock, with the expiry of its lease if it was
+// acquired through the server.
+func (s *Server) describe(name, value string) Lock {
+	lock := Lock{Name: name, Value: value, Locked: true}
+
+	s.mu.Lock()
+	if l, ok := s.leases[name]; ok && l.value == value {
+		expires := l.expires
+		lock.Expires = &expires
+	}
+	s.mu.Unlock()
+
+	return lock
+}
+
+// reap releases the locks of expired leases, and keeps the locks of live
+// ones fresh in the Store.
+func (s *Server) reap() {
+	defer close(s.done)
+
+	tick := time.NewTicker(reapInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-tick.C:
+		}
+
+		now := time.Now()
+		expired := make(map[string]string)
+		stale := make(map[string]string)
+
+		s.mu.Lock()
+		for name, l := range s.leases {
+			switch {
+			case !now.Before(l.expires):
+				expired[name] = l.value
+				delete(s.leases, name)
+			case now.Sub(l.freshened) >= s.refreshInterval():
+				stale[name] = l.value
+				l.freshened = now
+			}
+		}
+		s.mu.Unlock()
+
+		ctx := context.Background()
+		for name, value := range expired {
+			s.compareAndDelete(ctx, name, value)
+		}
+		for name, value := range stale {
+			if _, denied := s.Store.AcquireOrFreshenLock(ctx, name, value).(locker.LockDenied); denied {
+				// lost in the Store, the lease is worthless
+				s.mu.Lock()
+				if l, ok := s.leases[name]; ok && l.value == value {
+					delete(s.leases, name)
+				}
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (s *Server) ttl(requested int64) int64 {
+	max := s.MaxTTL
+	if max <= 0 {
+		max = 300
+	}
+
+	switch {
+	case requested <= 0 && s.TTL > 0:
+		return s.TTL
+	case requested <= 0:
+		return 5
+	case requested > max:
+		return max
+	}
+	return requested
+}
+
+func (s *Server) refreshInterval() time.Duration {
+	if s.Refresh <= 0 {
+		return time.Second
+	}
+	return s.Refresh
+}
+
+// post adapts a lock operation to a handler taking a JSON Request.
+func (s *Server) post(op func(context.Context, Request) (Lock, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, Error{"invalid request: " + err.Error(), CodeBadRequest})
+			return
+		}
+		if req.Name == "" || req.Value == "" {
+			writeError(w, Error{"name and value are required", CodeBadRequest})
+			return
+		}
+
+		resp, err := op(r.Context(), req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// get adapts a read to a GET handler.
+func (s *Server) get(op func(context.Context, *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp, err := op(r.Context(), r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// toError maps the errors of the Store onto API errors.
+func toError(err error) Error {
+	switch e := err.(type) {
+	case Error:
+		return e
+	case locker.LockDenied:
+		return Error{"lock is held by somebody else", CodeDenied}
+	case locker.LockNotFound:
+		return Error{"lock isn't held", CodeNotFound}
+	case locker.Unsupported:
+		return Error{err.Error(), CodeUnsupported}
+	case locker.ReasonRequired:
+		return Error{err.Error(), CodeBadRequest}
+	}
+	return Error{err.Error(), CodeStore}
+}
+
+var statuses = map[string]int{
+	CodeDenied:      http.StatusConflict,
+	CodeNotFound:    http.StatusNotFound,
+	CodeBadRequest:  http.StatusBadRequest,
+	CodeUnsupported: http.StatusNotImplemented,
+	CodeStore:       http.StatusBadGateway,
+	CodeForbidden:   http.StatusForbidden,
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e := toError(err)
+	writeJSON(w, statuses[e.Code], e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
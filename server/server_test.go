@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *locker.MemoryStore) {
+	store := &locker.MemoryStore{}
+	srv := New(store)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Close()
+	})
+	return ts, store
+}
+
+func post(t *testing.T, ts *httptest.Server, op string, req Request) (int, Lock, Error) {
+	t.Helper()
+
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(ts.URL+"/v1/"+op, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	return decode(t, resp)
+}
+
+func get(t *testing.T, ts *httptest.Server, path string) (int, Lock, Error) {
+	t.Helper()
+
+	resp, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	return decode(t, resp)
+}
+
+func decode(t *testing.T, resp *http.Response) (int, Lock, Error) {
+	t.Helper()
+
+	var lock Lock
+	var e Error
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&lock); err != nil {
+			t.Fatal(err)
+		}
+	} else if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, lock, e
+}
+
+func TestAcquireRefreshRelease(t *testing.T) {
+	ts, store := newTestServer(t)
+
+	status, lock, _ := post(t, ts, "acquire", Request{Name: "job", Value: "a", TTL: 10})
+	if status != http.StatusOK || !lock.Locked || lock.Value != "a" || lock.Expires == nil {
+		t.Fatalf("acquire: %d %+v", status, lock)
+	}
+	if v, _ := store.Get(context.Background(), "job"); v != "a" {
+		t.Errorf("Expected the lock in the store, got %q", v)
+	}
+
+	if status, _, e := post(t, ts, "acquire", Request{Name: "job", Value: "b"}); status != http.StatusConflict || e.Code != CodeDenied {
+		t.Errorf("Expected acquire by another value to be denied, got %d %+v", status, e)
+	}
+	if status, _, e := post(t, ts, "refresh", Request{Name: "job", Value: "b"}); status != http.StatusConflict || e.Code != CodeDenied {
+		t.Errorf("Expected refresh by another value to be denied, got %d %+v", status, e)
+	}
+	if status, _, _ := post(t, ts, "refresh", Request{Name: "job", Value: "a"}); status != http.StatusOK {
+		t.Errorf("Expected refresh by the owner, got %d", status)
+	}
+	if status, _, e := post(t, ts, "refresh", Request{Name: "other", Value: "a"}); status != http.StatusNotFound || e.Code != CodeNotFound {
+		t.Errorf("Expected refresh of a missing lock to fail, got %d %+v", status, e)
+	}
+
+	if status, lock, _ := get(t, ts, "/v1/inspect?name=job"); status != http.StatusOK || !lock.Locked || lock.Value != "a" {
+		t.Errorf("inspect: %d %+v", status, lock)
+	}
+
+	if status, _, _ := post(t, ts, "release", Request{Name: "job", Value: "b"}); status != http.StatusConflict {
+		t.Errorf("Expected release by another value to be denied, got %d", status)
+	}
+	if status, _, _ := post(t, ts, "release", Request{Name: "job", Value: "a"}); status != http.StatusOK {
+		t.Errorf("release: %d", status)
+	}
+	if status, lock, _ := get(t, ts, "/v1/inspect?name=job"); status != http.StatusOK || lock.Locked {
+		t.Errorf("Expected the lock to be released, got %d %+v", status, lock)
+	}
+
+	if status, _, e := post(t, ts, "acquire", Request{Name: "job"}); status != http.StatusBadRequest || e.Code != CodeBadRequest {
+		t.Errorf("Expected a request without value to be rejected, got %d %+v", status, e)
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	ts, store := newTestServer(t)
+
+	post(t, ts, "acquire", Request{Name: "job", Value: "a", TTL: 1})
+	time.Sleep(1500 * time.Millisecond)
+
+	if _, err := store.Get(context.Background(), "job"); err == nil {
+		t.Error("Expected the lock to be released after its lease expired")
+	}
+	if status, _, _ := post(t, ts, "acquire", Request{Name: "job", Value: "b"}); status != http.StatusOK {
+		t.Errorf("Expected the lock to be free, got %d", status)
+	}
+}
+
+func TestList(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	post(t, ts, "acquire", Request{Name: "jobs/a", Value: "1"})
+	post(t, ts, "acquire", Request{Name: "jobs/b", Value: "2"})
+	post(t, ts, "acquire", Request{Name: "other", Value: "3"})
+
+	resp, err := http.Get(ts.URL + "/v1/list?prefix=jobs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var list struct{ Locks []Lock }
+	json.NewDecoder(resp.Body).Decode(&list)
+	if len(list.Locks) != 2 || list.Locks[0].Name != "jobs/a" || list.Locks[1].Value != "2" {
+		t.Errorf("list: %+v", list.Locks)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ts, store := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/v1/watch?name=job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+
+	next := func() Lock {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("watch ended: %v", lines.Err())
+		}
+		var lock Lock
+		if err := json.Unmarshal(lines.Bytes(), &lock); err != nil {
+			t.Fatal(err)
+		}
+		return lock
+	}
+
+	if lock := next(); lock.Locked {
+		t.Errorf("Expected the lock to start out free, got %+v", lock)
+	}
+	store.AcquireOrFreshenLock(context.Background(), "job", "a")
+	if lock := next(); !lock.Locked || lock.Value != "a" {
+		t.Errorf("Expected the lock to be held, got %+v", lock)
+	}
+}
+
+// takenStore has its locks taken by "b" as soon as they're released, as
+// though their lease ran out right then.
+type takenStore struct {
+	locker.MemoryStore
+}
+
+func (s *takenStore) CompareAndDelete(ctx context.Context, name, value string) error {
+	if v, err := s.MemoryStore.Get(ctx, name); err == nil {
+		s.MemoryStore.Transfer(ctx, name, v, "b")
+	}
+	return s.MemoryStore.CompareAndDelete(ctx, name, value)
+}
+
+func TestReleaseChangedHands(t *testing.T) {
+	ctx := context.Background()
+	store := &takenStore{}
+	srv := New(store)
+	defer srv.Close()
+
+	if _, err := srv.acquire(ctx, Request{Name: "job", Value: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.release(ctx, Request{Name: "job", Value: "a"}); err != locker.NewLockDenied("job") {
+		t.Errorf("Expected LockDenied naming the lock, got %v", err)
+	}
+	if v, _ := store.MemoryStore.Get(ctx, "job"); v != "b" {
+		t.Errorf("Expected the new holder's lock to be left alone, got %q", v)
+	}
+	if _, err := srv.refresh(ctx, Request{Name: "job", Value: "c"}); err != locker.NewLockDenied("job") {
+		t.Errorf("Expected LockDenied naming the lock, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server side of the WebSocket protocol (RFC 6455), as much of it as
+// streaming events needs: the server sends text messages, and only reads
+// to answer pings and notice the client closing.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+const closeGoingAway = 1001
+
+// websocketWriteTimeout is how long a client has to take a frame before
+// it's given up on.
+var websocketWriteTimeout = 10 * time.Second
+
+type wsConn struct {
+	conn   net.Conn
+	rw     *bufio.ReadWriter
+	closed chan struct{}
+
+	mu sync.Mutex
+}
+
+// upgrade takes over the connection of r for the WebSocket protocol. A
+// page of another origin than the server's may only connect if it's one
+// of origins.
+func upgrade(w http.ResponseWriter, r *http.Request, origins []string) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		writeError(w, Error{"websocket upgrade expected", CodeBadRequest})
+		return nil, errors.New("not a websocket request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, Error{"unsupported websocket version", CodeBadRequest})
+		return nil, errors.New("unsupported websocket version")
+	}
+	if !allowedOrigin(r, origins) {
+		writeError(w, Error{"websocket origin isn't allowed", CodeForbidden})
+		return nil, errors.New("websocket origin isn't allowed")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, Error{"websocket isn't supported by the connection", CodeUnsupported})
+		return nil, errors.New("can't hijack the connection")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ws := &wsConn{conn: conn, rw: rw, closed: make(chan struct{})}
+	go ws.read()
+	return ws, nil
+}
+
+func (ws *wsConn) writeText(data []byte) error {
+	return ws.writeFrame(opText, data)
+}
+
+func (ws *wsConn) writeClose(code uint16) error {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	return ws.writeFrame(opClose, payload[:])
+}
+
+// writeFrame writes a single, unmasked, final frame.
+func (ws *wsConn) writeFrame(op byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	ws.rw.Write(header)
+	ws.rw.Write(payload)
+	return ws.rw.Flush()
+}
+
+// read answers pings until the client closes the connection, or it
+// breaks. Messages from the client are ignored.
+func (ws *wsConn) read() {
+	defer close(ws.closed)
+
+	for {
+		op, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case opPing:
+			ws.writeFrame(opPong, payload)
+		case opClose:
+			ws.writeFrame(opClose, payload)
+			return
+		}
+	}
+}
+
+// readFrame reads a frame of the client, which has to be masked.
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	op := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked client frame")
+	}
+
+	n := uint64(header[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > 1<<20 {
+		return 0, nil, errors.New("client frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+func (ws *wsConn) Close() error {
+	return ws.conn.Close()
+}
+
+// allowedOrigin tells whether the page which sent r may open a WebSocket:
+// requests from other than browsers have no Origin, and pages of the
+// server itself have its Host.
+func allowedOrigin(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerContains tells whether the comma separated header has token.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
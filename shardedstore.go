@@ -0,0 +1,256 @@
+package locker
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+)
+
+// Shard is a single backing store of a ShardedStore. The Name decides
+// which locks are placed on the shard, so it has to stay the same for as
+// long as the shard is in use, even if its Store is configured
+// differently.
+type Shard struct {
+	Name  string
+	Store Store
+}
+
+// ShardedStore is a backing store for Locker which spreads locks across
+// several Stores, for when a single etcd cluster can't keep up. Every
+// lock lives on exactly one shard, picked by rendezvous hashing of the
+// lock name, so adding or removing a shard only moves the locks placed
+// on it.
+//
+// Adding shards while locks are held needs a migration. Set Previous to
+// the shards as they were and Shards to the new set, on every client:
+//
+//     store := &locker.ShardedStore{
+//         Shards:   []locker.Shard{{"a", a}, {"b", b}, {"c", c}},
+//         Previous: []locker.Shard{{"a", a}, {"b", b}},
+//     }
+//
+// Locks still held on their previous shard are honoured, and move to
+// their new shard the next time their owner freshens them. Once every
+// client runs in migration mode and a TTL has passed, Previous can be
+// dropped.
+type ShardedStore struct {
+	// Shards are the stores locks are placed on. There has to be at
+	// least one.
+	Shards []Shard
+
+	// Previous are the shards before the last change. Setting it turns
+	// on migration mode.
+	Previous []Shard
+
+	// Log, if set, receives a debug event for every operation, with its
+	// key and latency.
+	Log Logger
+}
+
+// Get returns the value of a lock. LockNotFound will be returned if a
+// lock with the name isn't held.
+func (s *ShardedStore) Get(ctx context.Context, name string) (_ string, err error) {
+	defer s.log("Get", name, time.Now(), &err)
+
+	v, err := s.shard(name).Get(ctx, name)
+	if _, ok := err.(LockNotFound); ok {
+		if previous := s.previous(name); previous != nil {
+			return previous.Get(ctx, name)
+		}
+	}
+	return v, err
+}
+
+// AcquireOrFreshenLock will aquires a named lock if it isn't already
+// held, or updates its TTL if it is. In migration mode a lock held on
+// its previous shard is moved onto its new one.
+func (s *ShardedStore) AcquireOrFreshenLock(ctx context.Context, name, value string) (err error) {
+	defer s.log("AcquireOrFreshenLock", name, time.Now(), &err)
+
+	previous := s.previous(name)
+	if previous == nil {
+		return s.shard(name).AcquireOrFreshenLock(ctx, name, value)
+	}
+
+	// the previous shard has to be checked first, nobody takes a lock
+	// there in migration mode, so a free lock stays free
+	v, err := previous.Get(ctx, name)
+	switch err.(type) {
+	case nil:
+		if v != value {
+			return LockDenied{name}
+		}
+	case LockNotFound:
+	default:
+		return err
+	}
+
+	if err := s.shard(name).AcquireOrFreshenLock(ctx, name, value); err != nil {
+		return err
+	}
+	if v != value {
+		return nil
+	}
+
+	// it may have expired on the previous shard meanwhile, and been taken
+	// by somebody else, who keeps it
+	switch err := compareAndDelete(ctx, previous, name, value); err.(type) {
+	case nil, LockNotFound:
+		return nil
+	case LockDenied:
+		compareAndDelete(ctx, s.shard(name), name, value)
+		return err
+	default:
+		return err
+	}
+}
+
+// Delete releases the named lock, regardless of who holds it. In
+// migration mode it's released on its previous shard too.
+func (s *ShardedStore) Delete(ctx context.Context, name string) (err error) {
+	defer s.log("Delete", name, time.Now(), &err)
+
+	if previous := s.previous(name); previous != nil {
+		if err := previous.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	return s.shard(name).Delete(ctx, name)
+}
+
+// CompareAndDelete releases the named lock if it's held with value;
+// atomically on shards which are CompareAndDeleters. In migration mode
+// it's released on its previous shard too.
+func (s *ShardedStore) CompareAndDelete(ctx context.Context, name, value string) (err error) {
+	defer s.log("CompareAndDelete", name, time.Now(), &err)
+
+	stores := []Store{s.shard(name)}
+	if previous := s.previous(name); previous != nil {
+		stores = append(stores, previous)
+	}
+
+	err = LockNotFound{name}
+	for _, store := range stores {
+		switch e := compareAndDelete(ctx, store, name, value); e.(type) {
+		case nil:
+			err = nil
+		case LockNotFound:
+		case LockDenied:
+			if _, ok := err.(LockNotFound); ok {
+				err = e
+			}
+		default:
+			return e
+		}
+	}
+	return err
+}
+
+// Transfer gives the named lock held by from to to, with a fresh TTL.
+// In migration mode a lock still on its previous shard is transferred
+// there, and moves once its new holder freshens it. The shards have to
+// be Transferers.
+func (s *ShardedStore) Transfer(ctx context.Context, name, from, to string) (err error) {
+	defer s.log("Transfer", name, time.Now(), &err)
+
+	stores := []Store{s.shard(name)}
+	if previous := s.previous(name); previous != nil {
+		stores = append(stores, previous)
+	}
+
+	for _, store := range stores {
+		transferer, ok := store.(Transferer)
+		if !ok {
+			return Unsupported{"Transfer"}
+		}
+		err = transferer.Transfer(ctx, name, from, to)
+		if _, ok := err.(LockNotFound); !ok {
+			return err
+		}
+	}
+	return err
+}
+
+// List returns the held locks whose names start with prefix, mapped to
+// their values, from every shard. Every shard has to be a Lister.
+func (s *ShardedStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	locks := make(map[string]string)
+
+	// previous shards first, a lock which has moved is on its new shard
+	for _, shards := range [][]Shard{s.Previous, s.Shards} {
+		for _, shard := range shards {
+			lister, ok := shard.Store.(Lister)
+			if !ok {
+				return nil, Unsupported{"List"}
+			}
+			shardLocks, err := lister.List(ctx, prefix)
+			if err != nil {
+				return nil, err
+			}
+			for name, value := range shardLocks {
+				locks[name] = value
+			}
+		}
+	}
+	return locks, nil
+}
+
+// ShardName returns the name of the shard the named lock is placed on.
+func (s *ShardedStore) ShardName(name string) string {
+	return place(s.Shards, name).Name
+}
+
+// shard returns the store the named lock is placed on.
+func (s *ShardedStore) shard(name string) Store {
+	return place(s.Shards, name).Store
+}
+
+// previous returns the store the named lock was placed on before the
+// migration, or nil if it didn't move or there is no migration.
+func (s *ShardedStore) previous(name string) Store {
+	if len(s.Previous) == 0 {
+		return nil
+	}
+
+	previous := place(s.Previous, name)
+	if previous.Name == place(s.Shards, name).Name {
+		return nil
+	}
+	return previous.Store
+}
+
+// place picks the shard for a lock with rendezvous hashing: the shard
+// scoring the highest hash of its name and the lock name wins.
+func place(shards []Shard, name string) Shard {
+	var (
+		best  Shard
+		score uint64
+	)
+	for i, shard := range shards {
+		h := fnv.New64a()
+		h.Write([]byte(shard.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(name))
+		if sum := mix(h.Sum64()); i == 0 || sum > score {
+			best, score = shard, sum
+		}
+	}
+	return best
+}
+
+// mix is the splitmix64 finaliser. FNV on its own doesn't spread similar
+// inputs far enough apart for their scores to compare fairly.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// log sends the debug event of an operation, deferred by it, to the
+// Logger of the store.
+func (s *ShardedStore) log(op, name string, start time.Time, err *error) {
+	logStore(s.Log, "sharded", op, name, start, *err)
+}
@@ -0,0 +1,110 @@
+package locker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestShardedStorePlacement(t *testing.T) {
+	store := &ShardedStore{Shards: []Shard{{"a", &MemoryStore{}}, {"b", &MemoryStore{}}, {"c", &MemoryStore{}}}}
+	grown := &ShardedStore{Shards: append(store.Shards, Shard{"d", &MemoryStore{}})}
+
+	const locks = 4000
+	counts := make(map[string]int)
+	moved := 0
+	for i := 0; i < locks; i++ {
+		key := fmt.Sprintf("lock-%d", i)
+		before, after := store.ShardName(key), grown.ShardName(key)
+		counts[before]++
+
+		if before != store.ShardName(key) {
+			t.Fatalf("Placement of %s isn't stable", key)
+		}
+		if before != after {
+			moved++
+			if after != "d" {
+				t.Fatalf("%s moved from %s to %s, only moves to the new shard are expected", key, before, after)
+			}
+		}
+	}
+
+	for shard, n := range counts {
+		if n < locks/3*8/10 || n > locks/3*12/10 {
+			t.Errorf("Shard %s has %d of %d locks", shard, n, locks)
+		}
+	}
+	if moved < locks/4*8/10 || moved > locks/4*12/10 {
+		t.Errorf("Adding a fourth shard moved %d of %d locks", moved, locks)
+	}
+}
+
+func TestShardedStoreMigration(t *testing.T) {
+	old := []Shard{{"a", &MemoryStore{}}, {"b", &MemoryStore{}}}
+	ctx := context.Background()
+
+	// find a lock which moves to the new shard
+	store := &ShardedStore{Shards: old}
+	migrating := &ShardedStore{Shards: append(old, Shard{"c", &MemoryStore{}}), Previous: old}
+	var key string
+	for i := 0; key == ""; i++ {
+		if k := fmt.Sprintf("lock-%d", i); migrating.ShardName(k) == "c" {
+			key = k
+		}
+	}
+
+	if err := store.AcquireOrFreshenLock(ctx, key, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := migrating.Get(ctx, key); err != nil || v != "a" {
+		t.Errorf("Expected the lock on its previous shard to be found, got %q, %v", v, err)
+	}
+	if err := migrating.AcquireOrFreshenLock(ctx, key, "b"); err != (LockDenied{key}) {
+		t.Errorf("Expected LockDenied, got %v", err)
+	}
+
+	// freshening moves the lock onto its new shard
+	if err := migrating.AcquireOrFreshenLock(ctx, key, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, key); err != (LockNotFound{key}) {
+		t.Errorf("Expected the lock to have left its previous shard, got %v", err)
+	}
+
+	migrated := &ShardedStore{Shards: migrating.Shards}
+	if v, err := migrated.Get(ctx, key); err != nil || v != "a" {
+		t.Errorf("Expected the lock on its new shard, got %q, %v", v, err)
+	}
+	if err := migrated.AcquireOrFreshenLock(ctx, key, "b"); err != (LockDenied{key}) {
+		t.Errorf("Expected LockDenied, got %v", err)
+	}
+}
+
+func TestShardedStoreMigrationChangedHands(t *testing.T) {
+	old := []Shard{{"a", &takenStore{}}, {"b", &takenStore{}}}
+	ctx := context.Background()
+
+	migrating := &ShardedStore{Shards: append(old, Shard{"c", &MemoryStore{}}), Previous: old}
+	var key string
+	for i := 0; key == ""; i++ {
+		if k := fmt.Sprintf("lock-%d", i); migrating.ShardName(k) == "c" {
+			key = k
+		}
+	}
+	previous := &ShardedStore{Shards: old}
+	if err := previous.AcquireOrFreshenLock(ctx, key, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lock is taken by "b" on its previous shard while it's moved
+	if err := migrating.AcquireOrFreshenLock(ctx, key, "a"); err != (LockDenied{key}) {
+		t.Errorf("Expected LockDenied, got %v", err)
+	}
+	if v, err := previous.Get(ctx, key); err != nil || v != "b" {
+		t.Errorf("Expected the new holder's lock to be left alone, got %q, %v", v, err)
+	}
+	if _, err := migrating.Shards[2].Store.Get(ctx, key); err != (LockNotFound{key}) {
+		t.Errorf("Expected the lock to be released on its new shard, got %v", err)
+	}
+}
@@ -0,0 +1,518 @@
+// Package simulation runs locker Clients competing for a lock against a
+// shared MemoryStore, on a virtual clock, and injects network partitions,
+// process pauses and lease expiries according to a seeded schedule. It
+// checks that the lock stays mutually exclusive, and that no two Handles
+// take their lock for valid at once. The holders write to a resource
+// fenced with the lock's tokens, which rejects the writes of holders the
+// lock has moved on from.
+//
+//	res := simulation.Run(simulation.Config{
+//		Seed:          42,
+//		PartitionRate: 0.01,
+//		PauseRate:     0.01,
+//		ExpireRate:    0.005,
+//	})
+//	if !res.Ok() {
+//		t.Fatal(res)
+//	}
+//
+// Every step of the simulation, each client which isn't paused either
+// tries to take the lock with Client.Acquire, or, when it holds a Handle,
+// releases it, or checks that it's still valid and writes to the resource
+// on its next step. Handles freshen their lock, and recover it once lost,
+// on goroutines of their own. A pause between the check and the write is
+// the classic way for a holder to write after losing the lock; the
+// resource rejects such writes when a newer holder has written already.
+//
+// Every Handle has a fake clock of its own, which the simulation advances
+// and then waits on until the Handle is waiting for it again. The Handles
+// run one at a time, in an order decided by the seed alone, so running a
+// seed again replays it exactly.
+package simulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+	"github.com/PumpkinSeed/locker/clocktest"
+)
+
+// Name is the name of the lock the clients compete for.
+const Name = "resource"
+
+// errPartitioned is returned by the Store of a client cut off from it.
+var errPartitioned = errors.New("simulation: partitioned from the store")
+
+// Config configures a simulation. The zero values of the fields get
+// defaults, except for the fault rates.
+type Config struct {
+	// Seed seeds the schedule.
+	Seed int64
+
+	// Clients is the number of clients. Default: 5.
+	Clients int
+
+	// Steps is the number of steps to run. Default: 1000.
+	Steps int
+
+	// Tick is how far the clock moves every step. Default: 100ms.
+	Tick time.Duration
+
+	// TTL is the time-to-live of the lock in seconds. Default: 2.
+	TTL int64
+
+	// PartitionRate, PauseRate and ExpireRate are the fractions of steps
+	// in which a client is cut off from the store, a client is paused,
+	// and the lock's lease is expired under its holder.
+	PartitionRate float64
+	PauseRate     float64
+	ExpireRate    float64
+
+	// MaxFault is the longest a partition or a pause lasts. Default:
+	// twice the TTL.
+	MaxFault time.Duration
+
+	// Store creates the store the clients share. Default: a MemoryStore.
+	Store func(ttl int64, clock locker.Clock) locker.Store
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.Clients <= 0 {
+		cfg.Clients = 5
+	}
+	if cfg.Steps <= 0 {
+		cfg.Steps = 1000
+	}
+	if cfg.Tick <= 0 {
+		cfg.Tick = 100 * time.Millisecond
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 2
+	}
+	if cfg.MaxFault <= 0 {
+		cfg.MaxFault = 2 * time.Duration(cfg.TTL) * time.Second
+	}
+	if cfg.Store == nil {
+		cfg.Store = func(ttl int64, clock locker.Clock) locker.Store {
+			return &locker.MemoryStore{TTL: ttl, Clock: clock}
+		}
+	}
+	return cfg
+}
+
+// Event is something which happened in a simulation.
+type Event struct {
+	// At is the time since the start of the simulation.
+	At time.Duration
+
+	// Client is the client it happened to, empty for faults of the store.
+	Client string
+
+	Msg string
+}
+
+func (e Event) String() string {
+	who := e.Client
+	if who == "" {
+		who = "store"
+	}
+	return fmt.Sprintf("%8s %-6s %s", e.At, who, e.Msg)
+}
+
+// Violation is a broken invariant.
+type Violation struct {
+	At time.Duration
+
+	// Invariant is "mutual exclusion" or "validity".
+	Invariant string
+
+	Msg string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s violated: %s", v.At, v.Invariant, v.Msg)
+}
+
+// Result is the outcome of a simulation.
+type Result struct {
+	// Seed is the seed which replays the simulation.
+	Seed int64
+
+	// Events is what happened, in order.
+	Events []Event
+
+	// Violations are the broken invariants.
+	Violations []Violation
+
+	// Writes and Rejected count the writes the resource accepted, and
+	// those it rejected because of a stale token.
+	Writes   int
+	Rejected int
+}
+
+// Ok tells whether no invariant was broken.
+func (r Result) Ok() bool {
+	return len(r.Violations) == 0
+}
+
+// String describes the violations, with the events leading up to the
+// first one.
+func (r Result) String() string {
+	if r.Ok() {
+		return fmt.Sprintf("seed %d: ok, %d writes, %d rejected", r.Seed, r.Writes, r.Rejected)
+	}
+
+	lines := []string{fmt.Sprintf("seed %d:", r.Seed)}
+	for _, v := range r.Violations {
+		lines = append(lines, "  "+v.String())
+	}
+
+	end := 0
+	for end < len(r.Events) && r.Events[end].At <= r.Violations[0].At {
+		end++
+	}
+	start := end - 40
+	if start < 0 {
+		start = 0
+	}
+	lines = append(lines, "events:")
+	for _, e := range r.Events[start:end] {
+		lines = append(lines, "  "+e.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Run runs a simulation.
+func Run(cfg Config) Result {
+	cfg = cfg.withDefaults()
+
+	clock := clocktest.New(time.Unix(0, 0))
+	s := &sim{
+		cfg:    cfg,
+		rand:   rand.New(rand.NewSource(cfg.Seed)),
+		clock:  clock,
+		start:  clock.Now(),
+		result: Result{Seed: cfg.Seed},
+	}
+	s.store = &fencedStore{sim: s, Store: cfg.Store(cfg.TTL, clock), faulted: map[string]bool{}}
+
+	for i := 0; i < cfg.Clients; i++ {
+		c := &client{id: fmt.Sprintf("c%d", i)}
+		c.link = &link{sim: s, Store: s.store}
+		s.clients = append(s.clients, c)
+	}
+
+	for i := 0; i < cfg.Steps; i++ {
+		s.step()
+		clock.Advance(cfg.Tick)
+	}
+	return s.result
+}
+
+type sim struct {
+	cfg     Config
+	rand    *rand.Rand
+	clock   *clocktest.Clock
+	start   time.Time
+	store   *fencedStore
+	clients []*client
+	result  Result
+
+	// highest is the highest token the resource has seen.
+	highest int64
+}
+
+type client struct {
+	id   string
+	link *link
+
+	// handle is the lock the client holds, and clock the clock of the
+	// Handle, which is behind while the client is paused.
+	handle *locker.Handle
+	clock  *clocktest.Clock
+
+	pausedUntil time.Time
+	token       int64
+	writing     bool
+}
+
+// step injects the faults of a step, then lets every client which isn't
+// paused catch up with the clock and act, and checks the validity of the
+// Handles.
+func (s *sim) step() {
+	now := s.clock.Now()
+
+	for _, c := range s.clients {
+		if c.link.down && !now.Before(c.link.until) {
+			c.link.down = false
+			s.event(c.id, "partition healed")
+		}
+		if s.chance(s.cfg.PartitionRate) && !c.link.down {
+			c.link.down = true
+			c.link.until = now.Add(s.fault())
+			s.event(c.id, "partitioned until %s", c.link.until.Sub(s.start))
+		}
+		if s.chance(s.cfg.PauseRate) && !now.Before(c.pausedUntil) {
+			c.pausedUntil = now.Add(s.fault())
+			s.event(c.id, "paused until %s", c.pausedUntil.Sub(s.start))
+		}
+	}
+	if s.chance(s.cfg.ExpireRate) {
+		s.store.expire()
+	}
+
+	for _, c := range s.clients {
+		if now.Before(c.pausedUntil) {
+			continue
+		}
+		s.catchUp(c)
+		s.act(c)
+	}
+	s.checkValidity()
+}
+
+// catchUp advances the clock of the client's Handle to the time of the
+// simulation, and waits for the Handle to be done with what fell due.
+func (s *sim) catchUp(c *client) {
+	if c.handle == nil {
+		return
+	}
+	c.clock.Advance(s.clock.Now().Sub(c.clock.Now()))
+	c.clock.BlockUntil(1)
+}
+
+// act makes the next move of a client.
+func (s *sim) act(c *client) {
+	if c.writing {
+		// it checked its Handle before, maybe before a pause
+		c.writing = false
+		s.write(c)
+		return
+	}
+
+	if c.handle == nil {
+		if s.chance(0.3) {
+			s.acquire(c)
+		}
+		return
+	}
+
+	switch r := s.rand.Float64(); {
+	case r < 0.9:
+		if c.handle.StillValid() {
+			c.writing = true
+		}
+	default:
+		s.release(c)
+	}
+}
+
+// acquire takes the lock with a Handle which keeps freshening it, and
+// waits for the Handle to wait for its clock.
+func (s *sim) acquire(c *client) {
+	clock := clocktest.New(s.clock.Now())
+	client := locker.Client{
+		Store:    c.link,
+		Clock:    clock,
+		TTL:      time.Duration(s.cfg.TTL) * time.Second,
+		Recovery: locker.ReacquireAndWait,
+	}
+	h, err := client.Acquire(Name, c.id)
+	switch err.(type) {
+	case nil:
+	case locker.LockDenied:
+		return
+	default:
+		s.event(c.id, "acquire failed: %v", err)
+		return
+	}
+
+	c.handle, c.clock, c.token = h, clock, s.store.token()
+	s.event(c.id, "acquired with token %d", c.token)
+	h.OnOwnership(func(e locker.OwnershipEvent) { s.ownership(c, e) })
+	clock.BlockUntil(1)
+}
+
+// ownership follows the changes of the ownership of a client's Handle,
+// which happen while the simulation waits on its clock.
+func (s *sim) ownership(c *client, e locker.OwnershipEvent) {
+	switch e.State {
+	case locker.OwnershipLost:
+		s.event(c.id, "lost the lock: %v", e.Err)
+	case locker.OwnershipRecovered:
+		c.token = s.store.token()
+		s.event(c.id, "recovered the lock with token %d", c.token)
+	}
+}
+
+// release releases the client's Handle.
+func (s *sim) release(c *client) {
+	err := c.handle.Release()
+	c.handle, c.clock = nil, nil
+	if err != nil {
+		s.event(c.id, "release failed: %v", err)
+		return
+	}
+	s.event(c.id, "released")
+}
+
+// checkValidity checks that no two Handles take their lock for valid,
+// as StillValid would tell their clients now. A Handle whose lease the
+// store lost, which it can't know, is left to fencing until it's granted
+// the lock again.
+func (s *sim) checkValidity() {
+	now := s.clock.Now()
+
+	var valid []string
+	for _, c := range s.clients {
+		if c.handle != nil && !s.store.faulted[c.id] && now.Before(c.handle.ValidUntil()) {
+			valid = append(valid, c.id)
+		}
+	}
+	if len(valid) > 1 {
+		s.violate("validity", "%s take the lock for valid at once", strings.Join(valid, " and "))
+	}
+}
+
+// write writes to the resource with the client's token. The resource
+// rejects tokens older than the newest it has seen.
+func (s *sim) write(c *client) {
+	if c.token < s.highest {
+		s.result.Rejected++
+		s.event(c.id, "write with stale token %d rejected", c.token)
+		return
+	}
+	s.highest = c.token
+	s.result.Writes++
+	s.event(c.id, "wrote with token %d", c.token)
+}
+
+func (s *sim) chance(rate float64) bool {
+	// always draw, so the schedule doesn't depend on which rates are set
+	return s.rand.Float64() < rate
+}
+
+// fault draws how long a partition or a pause lasts.
+func (s *sim) fault() time.Duration {
+	return time.Duration(s.rand.Int63n(int64(s.cfg.MaxFault))) + 1
+}
+
+func (s *sim) event(client, format string, args ...interface{}) {
+	s.result.Events = append(s.result.Events, Event{
+		At:     s.clock.Now().Sub(s.start),
+		Client: client,
+		Msg:    fmt.Sprintf(format, args...),
+	})
+}
+
+func (s *sim) violate(invariant, format string, args ...interface{}) {
+	v := Violation{
+		At:        s.clock.Now().Sub(s.start),
+		Invariant: invariant,
+		Msg:       fmt.Sprintf(format, args...),
+	}
+	s.result.Violations = append(s.result.Violations, v)
+	s.event("", "VIOLATION %s", v.Msg)
+}
+
+// fencedStore wraps the shared store. It hands out a fencing token every
+// time the lock changes hands, and checks that it never does while the
+// lease of its holder is still running.
+type fencedStore struct {
+	locker.Store
+	sim *sim
+
+	holder  string
+	expires time.Time
+	next    int64
+
+	// faulted are the clients whose lease was expired under them, until
+	// they're granted the lock again.
+	faulted map[string]bool
+}
+
+func (f *fencedStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	if err := f.Store.AcquireOrFreshenLock(ctx, name, value); err != nil {
+		return err
+	}
+
+	now := f.sim.clock.Now()
+	held := f.holder != "" && now.Before(f.expires)
+	if held && f.holder != value {
+		f.sim.violate("mutual exclusion", "%s was granted the lock %s holds until %s",
+			value, f.holder, f.expires.Sub(f.sim.start))
+	}
+	if !held || f.holder != value {
+		f.holder = value
+		f.next++
+		delete(f.faulted, value)
+	}
+	f.expires = now.Add(time.Duration(f.sim.cfg.TTL) * time.Second)
+	return nil
+}
+
+func (f *fencedStore) Delete(ctx context.Context, name string) error {
+	if err := f.Store.Delete(ctx, name); err != nil {
+		return err
+	}
+	f.holder = ""
+	return nil
+}
+
+// token is the token of the current holder.
+func (f *fencedStore) token() int64 {
+	return f.next
+}
+
+// expire expires the lease of the holder, as though the store lost it.
+func (f *fencedStore) expire() {
+	if f.holder == "" || !f.sim.clock.Now().Before(f.expires) {
+		return
+	}
+	f.sim.event("", "lease of %s expired", f.holder)
+	f.faulted[f.holder] = true
+	f.Delete(context.Background(), Name)
+}
+
+// link is a client's connection to the store, which a partition cuts.
+// Calls during a partition fail, half of them after reaching the store,
+// as though only their answer was lost.
+type link struct {
+	locker.Store
+	sim *sim
+
+	down  bool
+	until time.Time
+}
+
+func (l *link) Get(ctx context.Context, name string) (string, error) {
+	if l.down {
+		return "", errPartitioned
+	}
+	return l.Store.Get(ctx, name)
+}
+
+func (l *link) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	if l.down {
+		if l.sim.chance(0.5) {
+			l.Store.AcquireOrFreshenLock(ctx, name, value)
+		}
+		return errPartitioned
+	}
+	return l.Store.AcquireOrFreshenLock(ctx, name, value)
+}
+
+func (l *link) Delete(ctx context.Context, name string) error {
+	if l.down {
+		if l.sim.chance(0.5) {
+			l.Store.Delete(ctx, name)
+		}
+		return errPartitioned
+	}
+	return l.Store.Delete(ctx, name)
+}
@@ -0,0 +1,141 @@
+package simulation
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+var faulty = Config{
+	PartitionRate: 0.01,
+	PauseRate:     0.01,
+	ExpireRate:    0.005,
+}
+
+// TestSeeds runs faulty simulations with many seeds. LOCKER_SIM_SEED
+// replays a single one, printing its events.
+func TestSeeds(t *testing.T) {
+	if env := os.Getenv("LOCKER_SIM_SEED"); env != "" {
+		seed, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := faulty
+		cfg.Seed = seed
+		res := Run(cfg)
+		for _, e := range res.Events {
+			t.Log(e)
+		}
+		if !res.Ok() {
+			t.Error(res)
+		}
+		return
+	}
+
+	rejected := 0
+	for seed := int64(1); seed <= 50; seed++ {
+		cfg := faulty
+		cfg.Seed = seed
+		res := Run(cfg)
+		if !res.Ok() {
+			t.Errorf("%s\nreplay with LOCKER_SIM_SEED=%d", res, seed)
+		}
+		if res.Writes == 0 {
+			t.Errorf("seed %d: nothing was written", seed)
+		}
+		rejected += res.Rejected
+	}
+	if rejected == 0 {
+		t.Error("expected some holders to write after losing the lock")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	cfg := faulty
+	cfg.Seed = 7
+	a, b := Run(cfg), Run(cfg)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("the same seed ran differently")
+	}
+
+	cfg.Seed = 8
+	if c := Run(cfg); reflect.DeepEqual(a.Events, c.Events) {
+		t.Error("different seeds ran the same")
+	}
+}
+
+// greedyStore grants the lock to whoever asks.
+type greedyStore struct {
+	locker.MemoryStore
+}
+
+func (s *greedyStore) AcquireOrFreshenLock(ctx context.Context, name, value string) error {
+	s.MemoryStore.Delete(ctx, name)
+	return s.MemoryStore.AcquireOrFreshenLock(ctx, name, value)
+}
+
+func TestDetectsBrokenStore(t *testing.T) {
+	cfg := faulty
+	cfg.Seed = 1
+	cfg.Store = func(ttl int64, clock locker.Clock) locker.Store {
+		return &greedyStore{locker.MemoryStore{TTL: ttl, Clock: clock}}
+	}
+	res := Run(cfg)
+	if res.Ok() {
+		t.Fatal("expected the greedy store to break mutual exclusion")
+	}
+	if v := res.Violations[0]; v.Invariant != "mutual exclusion" {
+		t.Errorf("expected mutual exclusion to break first, got %s", v)
+	}
+}
+
+func TestWithoutFaults(t *testing.T) {
+	res := Run(Config{Seed: 1})
+	if !res.Ok() || res.Rejected != 0 {
+		t.Errorf("expected no violations nor stale writes without faults, got %s", res)
+	}
+}
+
+// shortStore keeps locks for half their TTL, which the Clients don't know.
+type shortStore struct {
+	locker.MemoryStore
+}
+
+func TestDetectsShortLeases(t *testing.T) {
+	cfg := faulty
+	cfg.Seed = 1
+	cfg.Store = func(ttl int64, clock locker.Clock) locker.Store {
+		return &shortStore{locker.MemoryStore{TTL: ttl / 2, Clock: clock}}
+	}
+	res := Run(cfg)
+	for _, v := range res.Violations {
+		if v.Invariant == "validity" {
+			return
+		}
+	}
+	t.Errorf("expected Handles outliving their leases to break validity, got %s", res)
+}
+
+func TestHandlesRecover(t *testing.T) {
+	lost, recovered := 0, 0
+	for seed := int64(1); seed <= 50; seed++ {
+		cfg := faulty
+		cfg.Seed = seed
+		for _, e := range Run(cfg).Events {
+			switch {
+			case strings.HasPrefix(e.Msg, "lost the lock"):
+				lost++
+			case strings.HasPrefix(e.Msg, "recovered the lock"):
+				recovered++
+			}
+		}
+	}
+	if lost == 0 || recovered == 0 {
+		t.Errorf("expected Handles to lose and recover the lock, %d lost, %d recovered", lost, recovered)
+	}
+}
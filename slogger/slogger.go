@@ -0,0 +1,43 @@
+// Package slogger adapts a log/slog Logger into a locker.Logger, so the
+// log events of Clients and Stores land in the slog pipeline with their
+// fields as attributes.
+//
+//	client := locker.Client{Store: store, Logger: slogger.New(slog.Default())}
+package slogger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+type logger struct {
+	l *slog.Logger
+}
+
+// New returns a locker.Logger logging to l. Levels map onto the slog
+// levels of the same name.
+func New(l *slog.Logger) locker.Logger {
+	return logger{l}
+}
+
+func (l logger) Log(level locker.Level, msg string, fields ...locker.Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	l.l.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level locker.Level) slog.Level {
+	switch level {
+	case locker.LevelDebug:
+		return slog.LevelDebug
+	case locker.LevelInfo:
+		return slog.LevelInfo
+	case locker.LevelWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
@@ -0,0 +1,33 @@
+package slogger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	l.Log(locker.LevelDebug, "hidden")
+	l.Log(locker.LevelWarn, "lock lost",
+		locker.Field{Key: locker.FieldKey, Value: "jobs/1"},
+		locker.Field{Key: locker.FieldLatency, Value: 2 * time.Second},
+		locker.Field{Key: locker.FieldError, Value: errors.New("store down")})
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("debug event logged at info: %s", out)
+	}
+	for _, want := range []string{"level=WARN", `msg="lock lost"`, "key=jobs/1", "latency=2s", `error="store down"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in %s", want, out)
+		}
+	}
+}
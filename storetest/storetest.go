@@ -0,0 +1,353 @@
+// Package storetest is a conformance test suite for locker Stores.
+//
+// Every Store has to provide the same guarantees for a locker Client to
+// be correct on top of it. RunConformance checks a Store against them:
+//
+//	func TestConformance(t *testing.T) {
+//	    storetest.RunConformance(t, func(t *testing.T, ttl int64) locker.Store {
+//	        return &mystore.Store{TTL: ttl}
+//	    })
+//	}
+//
+// The suite includes expiry checks, so a full run takes a few seconds.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/locker"
+)
+
+// Factory returns a Store for a single test. Locks held by the Store have
+// to expire ttl seconds after they were last acquired or freshened.
+// Stores may be shared between tests, every test uses its own lock names.
+type Factory func(t *testing.T, ttl int64) locker.Store
+
+// longTTL is used where a test doesn't want locks to expire under it.
+const longTTL = 30
+
+var sequence int64
+
+// RunConformance runs the conformance suite against the Stores returned by
+// factory, each check as its own subtest.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, Factory)
+	}{
+		{"GetMissing", testGetMissing},
+		{"Acquire", testAcquire},
+		{"FreshenByOwner", testFreshenByOwner},
+		{"DeniedForOthers", testDeniedForOthers},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+		{"IndependentNames", testIndependentNames},
+		{"ConcurrentAcquire", testConcurrentAcquire},
+		{"Expiry", testExpiry},
+		{"FreshenExtendsExpiry", testFreshenExtendsExpiry},
+		{"List", testList},
+		{"Transfer", testTransfer},
+		{"CompareAndDelete", testCompareAndDelete},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, factory)
+		})
+	}
+}
+
+func testGetMissing(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	name := lockName()
+
+	expectNotFound(t, store, name)
+}
+
+func testAcquire(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	name := lockName()
+
+	if err := store.AcquireOrFreshenLock(ctx(), name, "a"); err != nil {
+		t.Fatalf("AcquireOrFreshenLock of a free lock: %v", err)
+	}
+	expectValue(t, store, name, "a")
+}
+
+func testFreshenByOwner(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	name := lockName()
+
+	acquire(t, store, name, "a")
+	if err := store.AcquireOrFreshenLock(ctx(), name, "a"); err != nil {
+		t.Fatalf("AcquireOrFreshenLock by the owner: %v", err)
+	}
+	expectValue(t, store, name, "a")
+}
+
+func testDeniedForOthers(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	name := lockName()
+
+	acquire(t, store, name, "a")
+	expectDenied(t, store, name, "b")
+	expectValue(t, store, name, "a")
+}
+
+func testDelete(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	name := lockName()
+
+	acquire(t, store, name, "a")
+	if err := store.Delete(ctx(), name); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	expectNotFound(t, store, name)
+
+	if err := store.AcquireOrFreshenLock(ctx(), name, "b"); err != nil {
+		t.Fatalf("AcquireOrFreshenLock after Delete: %v", err)
+	}
+	expectValue(t, store, name, "b")
+}
+
+func testDeleteMissing(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	name := lockName()
+
+	if err := store.Delete(ctx(), name); err != nil {
+		t.Fatalf("Delete of a missing lock: %v", err)
+	}
+}
+
+func testIndependentNames(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	first, second := lockName(), lockName()
+
+	acquire(t, store, first, "a")
+	acquire(t, store, second, "b")
+	expectValue(t, store, first, "a")
+	expectValue(t, store, second, "b")
+
+	if err := store.Delete(ctx(), first); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	expectNotFound(t, store, first)
+	expectValue(t, store, second, "b")
+}
+
+func testConcurrentAcquire(t *testing.T, factory Factory) {
+	const clients = 16
+
+	store := factory(t, longTTL)
+	name := lockName()
+
+	var (
+		wg      sync.WaitGroup
+		winners int32
+		mu      sync.Mutex
+		winner  string
+	)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(value string) {
+			defer wg.Done()
+
+			err := store.AcquireOrFreshenLock(ctx(), name, value)
+			switch err.(type) {
+			case nil:
+				atomic.AddInt32(&winners, 1)
+				mu.Lock()
+				winner = value
+				mu.Unlock()
+			case locker.LockDenied:
+			default:
+				t.Errorf("AcquireOrFreshenLock: %v", err)
+			}
+		}(fmt.Sprintf("client-%d", i))
+	}
+	wg.Wait()
+
+	if winners != 1 {
+		t.Fatalf("Expected exactly one client to acquire the lock, %d did", winners)
+	}
+	expectValue(t, store, name, winner)
+}
+
+func testExpiry(t *testing.T, factory Factory) {
+	const ttl = 1
+
+	store := factory(t, ttl)
+	name := lockName()
+
+	acquire(t, store, name, "a")
+	waitForExpiry(t, store, name, ttl)
+
+	if err := store.AcquireOrFreshenLock(ctx(), name, "b"); err != nil {
+		t.Fatalf("AcquireOrFreshenLock of an expired lock: %v", err)
+	}
+	expectValue(t, store, name, "b")
+}
+
+func testFreshenExtendsExpiry(t *testing.T, factory Factory) {
+	const ttl = 2
+
+	store := factory(t, ttl)
+	name := lockName()
+
+	acquire(t, store, name, "a")
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Duration(ttl) * time.Second * 2 / 3)
+		acquire(t, store, name, "a")
+	}
+	expectValue(t, store, name, "a")
+
+	waitForExpiry(t, store, name, ttl)
+}
+
+func testList(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	lister, ok := store.(locker.Lister)
+	if !ok {
+		t.Skip("Store isn't a Lister")
+	}
+
+	prefix := lockName() + "/"
+	acquire(t, store, prefix+"a", "a")
+	acquire(t, store, prefix+"b", "b")
+	acquire(t, store, lockName(), "c")
+	if err := store.Delete(ctx(), prefix+"b"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	locks, err := lister.List(ctx(), prefix)
+	if err != nil {
+		t.Fatalf("List(%q): %v", prefix, err)
+	}
+	if len(locks) != 1 || locks[prefix+"a"] != "a" {
+		t.Fatalf("List(%q) = %v, expected only %sa", prefix, locks, prefix)
+	}
+}
+
+func testTransfer(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	transferer, ok := store.(locker.Transferer)
+	if !ok {
+		t.Skip("Store isn't a Transferer")
+	}
+	name := lockName()
+
+	acquire(t, store, name, "a")
+	if err := transferer.Transfer(ctx(), name, "a", "b"); err != nil {
+		t.Fatalf("Transfer by the holder: %v", err)
+	}
+	expectValue(t, store, name, "b")
+	expectDenied(t, store, name, "a")
+
+	if _, ok := transferer.Transfer(ctx(), name, "a", "c").(locker.LockDenied); !ok {
+		t.Error("Expected Transfer from a value not holding the lock to be denied")
+	}
+	expectValue(t, store, name, "b")
+
+	if _, ok := transferer.Transfer(ctx(), lockName(), "a", "b").(locker.LockNotFound); !ok {
+		t.Error("Expected Transfer of a lock which isn't held to return LockNotFound")
+	}
+}
+
+func testCompareAndDelete(t *testing.T, factory Factory) {
+	store := factory(t, longTTL)
+	deleter, ok := store.(locker.CompareAndDeleter)
+	if !ok {
+		t.Skip("Store isn't a CompareAndDeleter")
+	}
+	name := lockName()
+
+	acquire(t, store, name, "a")
+	if _, ok := deleter.CompareAndDelete(ctx(), name, "b").(locker.LockDenied); !ok {
+		t.Error("Expected CompareAndDelete with a value not holding the lock to be denied")
+	}
+	expectValue(t, store, name, "a")
+
+	if err := deleter.CompareAndDelete(ctx(), name, "a"); err != nil {
+		t.Fatalf("CompareAndDelete by the holder: %v", err)
+	}
+	expectNotFound(t, store, name)
+
+	if _, ok := deleter.CompareAndDelete(ctx(), name, "a").(locker.LockNotFound); !ok {
+		t.Error("Expected CompareAndDelete of a lock which isn't held to return LockNotFound")
+	}
+}
+
+// waitForExpiry waits for the named lock to be gone. Stores are allowed a
+// second of slack on top of the TTL, as lease based stores round to it.
+func waitForExpiry(t *testing.T, store locker.Store, name string, ttl int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Duration(ttl+1) * time.Second)
+	for {
+		_, err := store.Get(ctx(), name)
+		if _, ok := err.(locker.LockNotFound); ok {
+			return
+		}
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected lock %q to expire after %ds", name, ttl)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func acquire(t *testing.T, store locker.Store, name, value string) {
+	t.Helper()
+
+	if err := store.AcquireOrFreshenLock(ctx(), name, value); err != nil {
+		t.Fatalf("AcquireOrFreshenLock(%q, %q): %v", name, value, err)
+	}
+}
+
+func expectValue(t *testing.T, store locker.Store, name, value string) {
+	t.Helper()
+
+	v, err := store.Get(ctx(), name)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", name, err)
+	}
+	if v != value {
+		t.Fatalf("Get(%q) = %q, expected %q", name, v, value)
+	}
+}
+
+func expectNotFound(t *testing.T, store locker.Store, name string) {
+	t.Helper()
+
+	_, err := store.Get(ctx(), name)
+	if _, ok := err.(locker.LockNotFound); !ok {
+		t.Fatalf("Get(%q) expected LockNotFound, got %v", name, err)
+	}
+}
+
+func expectDenied(t *testing.T, store locker.Store, name, value string) {
+	t.Helper()
+
+	err := store.AcquireOrFreshenLock(ctx(), name, value)
+	if _, ok := err.(locker.LockDenied); !ok {
+		t.Fatalf("AcquireOrFreshenLock(%q, %q) expected LockDenied, got %v", name, value, err)
+	}
+}
+
+// lockName returns a name no other test uses, so Stores backed by a
+// shared cluster can run the suite repeatedly.
+func lockName() string {
+	return fmt.Sprintf("storetest-%d-%d", time.Now().UnixNano(), atomic.AddInt64(&sequence, 1))
+}
+
+func ctx() context.Context {
+	return context.Background()
+}
@@ -0,0 +1,155 @@
+package locker
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer starts the spans a Client puts around its calls, and around
+// every operation it makes on its Store. It maps directly onto the
+// tracer of OpenTelemetry, or any other tracing library:
+//
+//     type otelTracer struct{ trace.Tracer }
+//
+//     func (t otelTracer) Start(ctx context.Context, name string) (context.Context, locker.Span) {
+//       ctx, span := t.Tracer.Start(ctx, name)
+//       return ctx, otelSpan{span}
+//     }
+//
+// Spans are started from the context of the Client, see WithContext, and
+// the context of a span is handed on to the Store, so Store spans are
+// children of the span of the call making them.
+type Tracer interface {
+	// Start starts a span named name, as a child of the span in ctx if
+	// there's one, and returns a context carrying it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttributes records attributes on the span.
+	SetAttributes(attrs ...Attribute)
+
+	// End ends the span, with the error of the call if it failed.
+	// LockDenied and LockNotFound are outcomes rather than failures, and
+	// end spans with nil.
+	End(err error)
+}
+
+// Attribute is a key and value recorded on a Span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// The keys of the attributes set on spans.
+const (
+	// AttrName is the name of the lock.
+	AttrName = "locker.name"
+
+	// AttrOutcome is how the call went, one of the Outcome constants.
+	AttrOutcome = "locker.outcome"
+
+	// AttrAttempts is how many times Lock tried to acquire the lock: none
+	// if it found the lock held already.
+	AttrAttempts = "locker.attempts"
+
+	// AttrWait is how long Lock waited for the lock, a time.Duration.
+	AttrWait = "locker.wait"
+)
+
+// The values of AttrOutcome.
+const (
+	OutcomeOK       = "ok"
+	OutcomeAcquired = "acquired"
+	OutcomeDenied   = "denied"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+// noTracer is used by Clients without a Tracer.
+type noTracer struct{}
+
+func (noTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noSpan{}
+}
+
+type noSpan struct{}
+
+func (noSpan) SetAttributes(...Attribute) {}
+func (noSpan) End(error)                  {}
+
+// WithContext returns a copy of the Client which makes its calls with
+// ctx: the Store gets it, and whatever it carries, for every operation,
+// and Lock and Watch stop once it's done.
+//
+//     report := client.WithContext(ctx).Lock("my-service", value, nil)
+//
+func (c Client) WithContext(ctx context.Context) Client {
+	c.ctx = ctx
+	return c
+}
+
+func (c Client) tracer() Tracer {
+	if c.Tracer == nil {
+		return noTracer{}
+	}
+	return c.Tracer
+}
+
+// trace starts a span for a call of the Client on the named lock, and
+// returns a copy of the Client whose calls are made in it.
+func (c Client) trace(call, name string) (Client, Span) {
+	ctx, span := c.tracer().Start(c.context(), "locker."+call)
+	span.SetAttributes(Attribute{AttrName, name})
+	return c.WithContext(ctx), span
+}
+
+// traceStore runs op, an operation on the Store, in a span of its own.
+func (c Client) traceStore(op, name string, f func(ctx context.Context) error) error {
+	ctx, span := c.tracer().Start(c.context(), "locker.Store."+op)
+	span.SetAttributes(Attribute{AttrName, name})
+	return endSpan(span, c.guard(ctx, op, name, f))
+}
+
+// endLock ends the span of a Lock call, which tried to acquire the lock
+// attempts times.
+func endLock(span Span, report Report, start time.Time, attempts int) {
+	result := OutcomeAcquired
+	switch {
+	case report.Err != nil:
+		result = OutcomeError
+	case report.Msg == Fail:
+		result = OutcomeDenied
+	}
+	span.SetAttributes(
+		Attribute{AttrOutcome, result},
+		Attribute{AttrAttempts, attempts},
+		Attribute{AttrWait, time.Since(start)},
+	)
+	span.End(report.Err)
+}
+
+// endSpan ends the span of a call which returned err.
+func endSpan(span Span, err error) error {
+	result := outcome(err)
+	span.SetAttributes(Attribute{AttrOutcome, result})
+	if result == OutcomeError {
+		span.End(err)
+	} else {
+		span.End(nil)
+	}
+	return err
+}
+
+func outcome(err error) string {
+	switch err.(type) {
+	case nil:
+		return OutcomeOK
+	case LockDenied:
+		return OutcomeDenied
+	case LockNotFound:
+		return OutcomeNotFound
+	}
+	return OutcomeError
+}
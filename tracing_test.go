@@ -0,0 +1,145 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a Tracer which records the spans it starts, with the name
+// of their parent.
+type recorder struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	ended  bool
+	err    error
+}
+
+type spanKey struct{}
+
+func (r *recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+func (r *recorder) find(t *testing.T, name string) *recordedSpan {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.spans {
+		if s.name == name {
+			return s
+		}
+	}
+	t.Fatalf("no span %s", name)
+	return nil
+}
+
+func TestTraceLock(t *testing.T) {
+	tracer := &recorder{}
+	client := Client{Store: &memoryStore{}, Tracer: tracer}
+
+	quit := make(chan bool)
+	defer close(quit)
+	if report := client.Lock(name, "a", quit); report.Msg != Success {
+		t.Fatalf("lock: %+v", report)
+	}
+
+	lock := tracer.find(t, "locker.Lock")
+	if !lock.ended || lock.parent != "" || lock.err != nil {
+		t.Errorf("Lock span: %+v", lock)
+	}
+	if lock.attrs[AttrName] != name || lock.attrs[AttrOutcome] != OutcomeAcquired || lock.attrs[AttrAttempts] != 1 {
+		t.Errorf("Lock attributes: %v", lock.attrs)
+	}
+	if _, ok := lock.attrs[AttrWait].(time.Duration); !ok {
+		t.Errorf("Lock wait: %v", lock.attrs[AttrWait])
+	}
+
+	for span, parent := range map[string]string{
+		"locker.Inspect":                    "locker.Lock",
+		"locker.Get":                        "locker.Inspect",
+		"locker.Store.Get":                  "locker.Get",
+		"locker.Store.AcquireOrFreshenLock": "locker.Lock",
+	} {
+		if s := tracer.find(t, span); s.parent != parent || !s.ended {
+			t.Errorf("%s: %+v, want parent %s", span, s, parent)
+		}
+	}
+	if get := tracer.find(t, "locker.Store.Get"); get.attrs[AttrOutcome] != OutcomeNotFound || get.err != nil {
+		t.Errorf("Store.Get: %+v", get)
+	}
+
+	other := Client{Store: client.Store, Tracer: &recorder{}}
+	if report := other.Lock(name, "b", nil); report.Msg != Fail {
+		t.Fatalf("lock: %+v", report)
+	}
+	if lock := other.Tracer.(*recorder).find(t, "locker.Lock"); lock.attrs[AttrOutcome] != OutcomeDenied || lock.attrs[AttrAttempts] != 0 || lock.err != nil {
+		t.Errorf("denied Lock span: %+v", lock)
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	tracer := &recorder{}
+	ctx, parent := tracer.Start(context.Background(), "request")
+	client := Client{Store: &memoryStore{}, Tracer: tracer}.WithContext(ctx)
+
+	if err := client.Unlock(name, nil); err != nil {
+		t.Fatal(err)
+	}
+	parent.End(nil)
+
+	if s := tracer.find(t, "locker.Unlock"); s.parent != "request" {
+		t.Errorf("Unlock: %+v", s)
+	}
+	if s := tracer.find(t, "locker.Store.Delete"); s.parent != "locker.Unlock" || s.attrs[AttrOutcome] != OutcomeOK {
+		t.Errorf("Store.Delete: %+v", s)
+	}
+}
+
+func TestWatchContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := Client{Store: &memoryStore{}}.WithContext(ctx)
+
+	valueChanges := make(chan string, 1)
+	done := make(chan error)
+	go func() {
+		done <- client.Watch(name, valueChanges, nil)
+	}()
+
+	<-valueChanges
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-timeout():
+		t.Fatal("Watch didn't end with its context")
+	}
+}
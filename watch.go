@@ -21,7 +21,58 @@ import (
 //     }
 //
 // Watch is a blocking call, so it's recommended to run it in a goroutine.
-func (c Client) Watch(name string, valueChanges chan<- string, quit <-chan bool) error {
+//
+// If the Store is a Watcher the changes are pushed by the Store,
+// otherwise the lock is polled every few seconds. The watch ends, without
+// an error, once the context of the Client is done too.
+func (c Client) Watch(name string, valueChanges chan<- string, quit <-chan bool) (err error) {
+	c, span := c.trace("Watch", name)
+	defer func() { endSpan(span, err) }()
+
+	if w, ok := c.Store.(Watcher); ok {
+		ctx, cancel := context.WithCancel(c.context())
+		defer cancel()
+
+		// changes pass through here on their way to valueChanges, noted
+		// with when they came from the Store, so their lag counts from
+		// then however long the receiver takes
+		seen := make(chan string)
+		go func() {
+			type change struct {
+				value string
+				at    time.Time
+			}
+			var pending []change
+			for {
+				var out chan<- string
+				var next change
+				if len(pending) > 0 {
+					out, next = valueChanges, pending[0]
+				}
+				select {
+				case v := <-seen:
+					pending = append(pending, change{v, c.clock().Now()})
+				case out <- next.value:
+					c.metrics().WatchLag(name, c.clock().Now().Sub(next.at))
+					pending = pending[1:]
+				case <-quit:
+					cancel()
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		err := c.traceStore("Watch", name, func(ctx context.Context) error {
+			return w.Watch(ctx, name, seen)
+		})
+		if c.context().Err() != nil {
+			return nil
+		}
+		return c.storeError("Watch", name, err)
+	}
+
 	var lastValue string
 	first := true
 
@@ -29,24 +80,44 @@ func (c Client) Watch(name string, valueChanges chan<- string, quit <-chan bool)
 		select {
 		case <-quit:
 			return nil
+		case <-c.context().Done():
+			return nil
 		default:
-			v, err := c.Store.Get(context.Background(), name)
+			var v string
+			err := c.traceStore("Get", name, func(ctx context.Context) (err error) {
+				v, err = c.Store.Get(ctx, name)
+				return err
+			})
+			seen := c.clock().Now()
 
 			if err != nil {
 				if _, ok := err.(LockNotFound); ok {
 					v = ""
 				} else {
-					return err
+					return c.storeError("Get", name, err)
 				}
 			}
 
 			if v != lastValue || first {
-				valueChanges <- v
+				select {
+				case valueChanges <- v:
+					c.metrics().WatchLag(name, c.clock().Now().Sub(seen))
+				case <-quit:
+					return nil
+				case <-c.context().Done():
+					return nil
+				}
 				lastValue = v
 			}
 
 			first = false
-			time.Sleep(3 * time.Second)
+			select {
+			case <-c.clock().After(3 * time.Second):
+			case <-quit:
+				return nil
+			case <-c.context().Done():
+				return nil
+			}
 		}
 	}
 }